@@ -0,0 +1,513 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JoseTorrado/todo-cli/internal/todo"
+)
+
+// Subcommands, run as `todo <name> [args...]` next to the flags
+var commands = map[string]func(todos *todo.Todos, args []string) error{
+	"tui":       runTUI,
+	"done":      runDone,
+	"rm":        runRm,
+	"show":      runShow,
+	"report":    runReport,
+	"stats":     runStats,
+	"heatmap":   runHeatmap,
+	"burndown":  runChart("burndown"),
+	"flow":      runChart("flow"),
+	"start":     runStart,
+	"stop":      runStop,
+	"status":    runStatus,
+	"focus":     runFocus,
+	"estimate":  runEstimate,
+	"estimates": runEstimates,
+	"trash":     runTrash,
+	"restore":   runRestore,
+	"undo":      runUndo,
+	"redo":      runRedo,
+	"history":   runHistory,
+	"log":       runLog,
+	"archive":   runArchive,
+	"backup":    runBackup,
+	"doctor":    runDoctor,
+}
+
+func runTUI(todos *todo.Todos, args []string) error {
+	return todos.RunTUI()
+}
+
+// todo done [id or text], without arguments a picker over the pending todos comes up
+func runDone(todos *todo.Todos, args []string) error {
+	return completeTodo(todos, strings.Join(args, " "))
+}
+
+func runRm(todos *todo.Todos, args []string) error {
+	return deleteTodo(todos, strings.Join(args, " "))
+}
+
+// todo show <ref>, prints everything known about a single todo
+func runShow(todos *todo.Todos, args []string) error {
+	id, err := todos.Resolve(strings.Join(args, " "), os.Stdin, os.Stderr)
+	if err != nil {
+		return err
+	}
+	return todos.Show(id)
+}
+
+// todo report week|month|range, what got done over a longer period
+func runReport(todos *todo.Todos, args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	previous := fs.Bool("prev", false, "Report on the previous week or month instead of the current one")
+	since := fs.String("since", "", "Start of a range report (YYYY-MM-DD or RFC3339)")
+	until := fs.String("until", "", "End of a range report (YYYY-MM-DD, inclusive, or RFC3339), today when left out")
+	format := fs.String("format", todo.FormatText, "Output format: text, markdown or html")
+	output := fs.String("out", "", "Write the report to this file instead of printing it")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: todo report week|month|range [options]")
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 {
+		fs.Usage()
+		return fmt.Errorf("report needs a period")
+	}
+	period := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	now := time.Now().In(todos.Location())
+	var start, end time.Time
+	var err error
+	switch period {
+	case "range":
+		if *since == "" {
+			return fmt.Errorf("report range needs -since")
+		}
+		if start, err = parseTime(*since, false, todos.Location()); err != nil {
+			return err
+		}
+		end = now
+		if *until != "" {
+			if end, err = parseTime(*until, true, todos.Location()); err != nil {
+				return err
+			}
+		}
+	case todo.PeriodWeek, todo.PeriodMonth:
+		if start, end, err = todo.PeriodBounds(period, now, *previous); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown period %q, expected week, month or range", period)
+	}
+
+	report, err := todos.Report(start, end)
+	if err != nil {
+		return err
+	}
+	return writeReport(report, *format, *output)
+}
+
+// todo stats, created vs completed, lead times, streak and the like
+func runStats(todos *todo.Todos, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	days := fs.Int("days", 14, "Days covered by the daily sparklines")
+	weeks := fs.Int("weeks", 8, "Weeks covered by the weekly table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *days < 1 || *weeks < 1 {
+		return fmt.Errorf("-days and -weeks need to be at least 1")
+	}
+	return todos.PrintStats(time.Now(), *days, *weeks)
+}
+
+// todo heatmap, completed todos per day over the last year
+func runHeatmap(todos *todo.Todos, args []string) error {
+	fs := flag.NewFlagSet("heatmap", flag.ContinueOnError)
+	project := fs.String("project", "", "Only count todos in this project")
+	weeks := fs.Int("weeks", 53, "Weeks to show")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *weeks < 1 {
+		return fmt.Errorf("-weeks needs to be at least 1")
+	}
+	return todos.PrintHeatmap(time.Now(), *weeks, *project)
+}
+
+// todo burndown / todo flow, charts of a project over the last two weeks
+// unless a range is given
+func runChart(chart string) func(todos *todo.Todos, args []string) error {
+	return func(todos *todo.Todos, args []string) error {
+		fs := flag.NewFlagSet(chart, flag.ContinueOnError)
+		project := fs.String("project", "", "Only chart todos in this project")
+		since := fs.String("since", "", "First day of the chart (YYYY-MM-DD or RFC3339), two weeks ago by default")
+		until := fs.String("until", "", "Last day of the chart (YYYY-MM-DD, inclusive, or RFC3339), today by default")
+		csvPath := fs.String("csv", "", "Also write the daily counts to this CSV file")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+
+		loc := todos.Location()
+		end := time.Now().In(loc)
+		var err error
+		if *until != "" {
+			if end, err = parseTime(*until, true, loc); err != nil {
+				return err
+			}
+		}
+		start := end.Add(-time.Nanosecond).AddDate(0, 0, -13)
+		if *since != "" {
+			if start, err = parseTime(*since, false, loc); err != nil {
+				return err
+			}
+		}
+		if !start.Before(end) {
+			return fmt.Errorf("-since needs to be before -until")
+		}
+
+		var csvOut io.Writer
+		if *csvPath != "" {
+			f, err := os.Create(*csvPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			csvOut = f
+		}
+
+		if err := todos.PrintFlow(chart, *project, start, end, csvOut); err != nil {
+			return err
+		}
+		if *csvPath != "" {
+			fmt.Printf("Series written to %s\n", *csvPath)
+		}
+		return nil
+	}
+}
+
+// todo start <ref>, times work on a todo until `todo stop`
+func runStart(todos *todo.Todos, args []string) error {
+	id, err := todos.Resolve(strings.Join(args, " "), os.Stdin, os.Stderr)
+	if err != nil {
+		return err
+	}
+	return todos.Start(id)
+}
+
+func runStop(todos *todo.Todos, args []string) error {
+	item, spent, err := todos.Stop()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Stopped %s after %s\n", item.Task, spent.Round(time.Second))
+	return nil
+}
+
+// todo status, what the running timer is on
+func runStatus(todos *todo.Todos, args []string) error {
+	return todos.PrintTimer(time.Now())
+}
+
+// todo focus [options] <ref>, pomodoro rounds on a todo. Ctrl-C stops the
+// round and keeps what was worked so far
+func runFocus(todos *todo.Todos, args []string) error {
+	fs := flag.NewFlagSet("focus", flag.ContinueOnError)
+	work := fs.Int("work", 0, "Minutes per focus round, focus_minutes from the config by default")
+	rest := fs.Int("break", 0, "Minutes per break, break_minutes from the config by default")
+	rounds := fs.Int("rounds", 1, "Focus rounds to run, with a break in between")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *work < 0 || *rest < 0 {
+		return fmt.Errorf("-work and -break can't be negative")
+	}
+
+	id, err := todos.Resolve(strings.Join(fs.Args(), " "), os.Stdin, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	// Ctrl-C ends the countdown, the partial round still gets logged
+	ctx := todos.Context()
+	todos = todos.WithContext(context.WithoutCancel(ctx))
+
+	_, err = todos.Focus(ctx, id, todo.FocusOptions{
+		Work:   time.Duration(*work) * time.Minute,
+		Break:  time.Duration(*rest) * time.Minute,
+		Rounds: *rounds,
+	}, os.Stdout)
+	return err
+}
+
+// todo estimate <ref> <estimate>, the estimate goes last since the
+// reference can be text. "none" clears it
+func runEstimate(todos *todo.Todos, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: todo estimate <id or text> <points or duration>")
+	}
+	id, err := todos.Resolve(strings.Join(args[:len(args)-1], " "), os.Stdin, os.Stderr)
+	if err != nil {
+		return err
+	}
+	return todos.SetEstimate(id, args[len(args)-1])
+}
+
+// todo estimates, how estimates compared to the actual time per project
+func runEstimates(todos *todo.Todos, args []string) error {
+	fs := flag.NewFlagSet("estimates", flag.ContinueOnError)
+	project := fs.String("project", "", "Only look at todos in this project")
+	since := fs.String("since", "", "Only todos completed since (YYYY-MM-DD or RFC3339), all of them by default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var start time.Time
+	if *since != "" {
+		var err error
+		if start, err = parseTime(*since, false, todos.Location()); err != nil {
+			return err
+		}
+	}
+	return todos.PrintEstimates(*project, start)
+}
+
+// todo trash ls|purge, deleted todos wait in the trash until purged
+func runTrash(todos *todo.Todos, args []string) error {
+	if len(args) == 0 || args[0] == "ls" {
+		return todos.PrintTrash()
+	}
+	if args[0] != "purge" {
+		return fmt.Errorf("unknown trash command %q, expected ls or purge", args[0])
+	}
+
+	fs := flag.NewFlagSet("trash purge", flag.ContinueOnError)
+	olderThan := fs.String("older-than", "0d", "Only purge todos deleted longer ago than this, like 30d, 2w or 12h")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	age, err := todo.ParseAge(*olderThan)
+	if err != nil {
+		return err
+	}
+	n, err := todos.Purge(age)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Purged %d todos from the trash\n", n)
+	return nil
+}
+
+// todo archive [-older-than 90d] or todo archive search <text>. Archived
+// todos leave the lists but stay in reports, history and search
+func runArchive(todos *todo.Todos, args []string) error {
+	if len(args) > 0 && args[0] == "search" {
+		return todos.PrintArchive(strings.Join(args[1:], " "))
+	}
+
+	fs := flag.NewFlagSet("archive", flag.ContinueOnError)
+	olderThan := fs.String("older-than", "90d", "Archive todos done or cancelled longer ago than this, like 90d, 8w or 12h")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	age, err := todo.ParseAge(*olderThan)
+	if err != nil {
+		return err
+	}
+	n, err := todos.Archive(age)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Archived %d todos\n", n)
+	return nil
+}
+
+// todo backup [ls | restore <name>], without arguments a backup is taken now
+func runBackup(todos *todo.Todos, args []string) error {
+	if len(args) == 0 {
+		name, err := todos.Backup()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Backed up to %s\n", name)
+		return nil
+	}
+
+	switch args[0] {
+	case "ls":
+		return todos.PrintBackups()
+	case "restore":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: todo backup restore <name>, see todo backup ls")
+		}
+		if err := todos.RestoreBackup(args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Restored %s, the previous state was backed up first\n", args[1])
+		return nil
+	}
+	return fmt.Errorf("unknown backup command %q, expected ls or restore", args[0])
+}
+
+// todo doctor [-repair], exits with an error while problems are left so it
+// can run from cron
+func runDoctor(todos *todo.Todos, args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	repair := fs.Bool("repair", false, "Fix the problems found, after backing up the database")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	left, err := todos.Doctor(*repair, os.Stdout)
+	if err != nil {
+		return err
+	}
+	if left > 0 {
+		return fmt.Errorf("%d problems left", left)
+	}
+	return nil
+}
+
+// todo restore <id|handle>, takes the ID or handle shown by `todo trash ls`
+// since trashed todos can't be looked up by text
+func runRestore(todos *todo.Todos, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: todo restore <id|handle>")
+	}
+	id, err := todos.ResolveTrashed(args[0])
+	if err != nil {
+		return err
+	}
+	return todos.Restore(id)
+}
+
+// todo undo [n], reverts the last n changes, one by default
+func runUndo(todos *todo.Todos, args []string) error {
+	n, err := countArg(args)
+	if err != nil {
+		return err
+	}
+	ops, err := todos.Undo(n)
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		fmt.Printf("Undid %s\n", op.Summary)
+	}
+	return nil
+}
+
+// todo redo [n], applies the last n undone changes again
+func runRedo(todos *todo.Todos, args []string) error {
+	n, err := countArg(args)
+	if err != nil {
+		return err
+	}
+	ops, err := todos.Redo(n)
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		fmt.Printf("Redid %s\n", op.Summary)
+	}
+	return nil
+}
+
+// todo history <ref>, every change made to a todo. Trashed and purged todos
+// are found by their ID
+func runHistory(todos *todo.Todos, args []string) error {
+	id, err := todos.Resolve(strings.Join(args, " "), os.Stdin, os.Stderr)
+	if err != nil {
+		return err
+	}
+	return todos.PrintHistory(id)
+}
+
+// todo log, changes to all todos, today's by default
+func runLog(todos *todo.Todos, args []string) error {
+	fs := flag.NewFlagSet("log", flag.ContinueOnError)
+	since := fs.String("since", "", "Start of the period (YYYY-MM-DD or RFC3339), today by default")
+	until := fs.String("until", "", "End of the period (YYYY-MM-DD, inclusive, or RFC3339), open by default")
+	all := fs.Bool("all", false, "Show every change ever recorded")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var start, end time.Time
+	var err error
+	switch {
+	case *all:
+	case *since != "":
+		if start, err = parseTime(*since, false, todos.Location()); err != nil {
+			return err
+		}
+	default:
+		now := time.Now().In(todos.Location())
+		start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	}
+	if *until != "" {
+		if end, err = parseTime(*until, true, todos.Location()); err != nil {
+			return err
+		}
+	}
+	return todos.PrintLog(start, end)
+}
+
+// Optional count for undo and redo
+func countArg(args []string) (int, error) {
+	if len(args) == 0 {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 || len(args) > 1 {
+		return 0, fmt.Errorf("expected how many changes, like 3, got %q", strings.Join(args, " "))
+	}
+	return n, nil
+}
+
+// Resolves an ID, handle or text reference, exiting when that fails
+func mustResolve(todos *todo.Todos, ref string) int {
+	id, err := todos.Resolve(ref, os.Stdin, os.Stderr)
+	if err != nil {
+		exit(err)
+	}
+	return id
+}
+
+func completeTodo(todos *todo.Todos, ref string) error {
+	id, err := todos.Resolve(ref, os.Stdin, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	unblocked, err := todos.Complete(id)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range unblocked {
+		fmt.Printf("Unblocked: %d %s\n", item.ID, item.Task)
+	}
+	return nil
+}
+
+func deleteTodo(todos *todo.Todos, ref string) error {
+	id, err := todos.Resolve(ref, os.Stdin, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if err := todos.Delete(id); err != nil {
+		return err
+	}
+	fmt.Printf("Moved %d to the trash, undo with todo restore %d\n", id, id)
+	return nil
+}
@@ -2,14 +2,19 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"os/user"
 	"path/filepath"
 	"strings"
 	"time"
+	// Timezones from the config work without a system zoneinfo database
+	_ "time/tzdata"
 
 	"github.com/JoseTorrado/todo-cli/internal/todo"
 )
@@ -57,12 +62,68 @@ func main() {
 	// Create a new Todos instance
 	todos := todo.NewTodos(db)
 
+	// The config lives next to the database
+	config, err := todo.LoadConfig(filepath.Dir(dbPath))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading config: ", err)
+		os.Exit(1)
+	}
+	if err := todos.SetConfig(config); err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading config: ", err)
+		os.Exit(1)
+	}
+
+	// Ctrl-C cancels the query in flight, see interruptible
+	ctx, stop := interruptible()
+	defer stop()
+	todos = todos.WithContext(ctx)
+
+	// Housekeeping failing only gets a warning, and the commands that repair
+	// or restore the database skip it, so they stay usable when it is damaged
+	if !recovering(os.Args[1:]) {
+		// backup_every_hours, taken before anything else touches the data
+		if _, err := todos.ScheduledBackup(time.Now()); err != nil {
+			warn("backing up the database failed", err)
+		}
+
+		// archive_after_days keeps the todos table small without anyone asking
+		if _, err := todos.AutoArchive(time.Now()); err != nil {
+			warn("archiving old todos failed", err)
+		}
+	}
+
+	// Subcommands take over before the flags are parsed
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(todos, os.Args[2:]); err != nil {
+				exit(err)
+			}
+			return
+		}
+	}
+
 	add := flag.Bool("add", false, "Add a new todo")
-	complete := flag.Int("done", 0, "Mark a todo as Completed")
-	del := flag.Int("rm", 0, "Delete a todo")
+	project := flag.String("project", "", "Project of the added todo, or the project to set with -assign")
+	assign := flag.String("assign", "", "Move a todo to the project given in -project")
+	board := flag.Bool("board", false, "Show pending and recent todos as a board")
+	groupBy := flag.String("group", "status", "Board columns: status or project")
+	estimate := flag.String("estimate", "", "Estimate of the added todo, in points (3, 3pt) or as a duration (90m, 2h)")
+	every := flag.String("every", "", "Make the added todo recurring: daily, weekdays, weekly[:mon,thu], monthly, every:3d or after:2d")
+	complete := flag.String("done", "", "Mark a todo as Completed, by ID or by matching text")
+	del := flag.String("rm", "", "Delete a todo, by ID or by matching text")
 	list := flag.Bool("ls", false, "List all the todos")
 	standup := flag.Bool("standup", false, "Print all tasks completed yesterday")
 	today := flag.Bool("today", false, "Print all tasks leftover for today")
+	since := flag.String("since", "", "Start of the -standup period (YYYY-MM-DD or RFC3339), instead of the configured lookback")
+	format := flag.String("format", todo.FormatText, "Output format of -standup: text, slack or html")
+	output := flag.String("out", "", "Write the -standup report to this file instead of printing it")
+	until := flag.String("until", "", "End of the -standup period (YYYY-MM-DD, inclusive, or RFC3339)")
+	block := flag.String("block", "", "Mark a todo as blocked by the todo given in -by")
+	unblock := flag.String("unblock", "", "Remove the dependency on the todo given in -by")
+	by := flag.String("by", "", "The blocking todo, used with -block and -unblock")
+	withBlocked := flag.Bool("blocked", false, "Include blocked tasks in -today")
+	move := flag.String("move", "", "Move a todo to the workflow state given in -to")
+	to := flag.String("to", "", "Workflow state for -move: "+strings.Join(todo.Statuses, ", "))
 
 	flag.Parse()
 
@@ -70,52 +131,102 @@ func main() {
 	case *add:
 		task, err := getInput(os.Stdin, flag.Args()...)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err.Error())
-			os.Exit(1)
+			exit(err)
 		}
 
-		if err := todos.Add(task); err != nil {
-			fmt.Fprintln(os.Stderr, err.Error())
-			os.Exit(1)
+		err = todos.AddWithOptions(task, todo.AddOptions{Project: *project, Recurrence: *every, Estimate: *estimate})
+		if err != nil {
+			exit(err)
+		}
+
+	case *complete != "":
+		if err := completeTodo(todos, *complete); err != nil {
+			exit(err)
 		}
 
-	case *complete > 0:
-		err := todos.Complete(*complete)
+	case *move != "":
+		unblocked, err := todos.Move(mustResolve(todos, *move), *to)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err.Error())
+			exit(err)
+		}
+
+		for _, item := range unblocked {
+			fmt.Printf("Unblocked: %d %s\n", item.ID, item.Task)
+		}
+
+	case *assign != "":
+		if err := todos.SetProject(mustResolve(todos, *assign), *project); err != nil {
+			exit(err)
+		}
+
+	case *board:
+		if err := todos.PrintBoard(*groupBy); err != nil {
+			exit(err)
+		}
+
+	case *block != "":
+		if *by == "" {
+			fmt.Fprintln(os.Stderr, "-block needs the blocking todo passed with -by")
 			os.Exit(1)
 		}
+		if err := todos.Block(mustResolve(todos, *block), mustResolve(todos, *by)); err != nil {
+			exit(err)
+		}
 
-	case *del > 0:
-		err := todos.Delete(*del)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err.Error())
+	case *unblock != "":
+		if *by == "" {
+			fmt.Fprintln(os.Stderr, "-unblock needs the blocking todo passed with -by")
 			os.Exit(1)
 		}
+		if err := todos.Unblock(mustResolve(todos, *unblock), mustResolve(todos, *by)); err != nil {
+			exit(err)
+		}
+
+	case *del != "":
+		if err := deleteTodo(todos, *del); err != nil {
+			exit(err)
+		}
 
 	case *list:
 		if err := todos.Print(); err != nil {
-			fmt.Fprintln(os.Stderr, err.Error())
-			os.Exit(1)
+			exit(err)
 		}
 
 	case *standup:
-		tasks, lookbackDate := todos.GetStandupTasks(time.Now())
+		now := time.Now()
+		lookbackDate, err := todos.StandupSince(now)
+		if *since != "" {
+			lookbackDate, err = parseTime(*since, false, todos.Location())
+		}
+		if err != nil {
+			exit(err)
+		}
 
-		// Print the lookback date
-		fmt.Printf("%s:\n", lookbackDate.Format("2006-01-02"))
+		var untilDate time.Time
+		if *until != "" {
+			if untilDate, err = parseTime(*until, true, todos.Location()); err != nil {
+				exit(err)
+			}
+		}
 
-		// Loop through the tasks and print them
-		if len(tasks) == 0 {
-			fmt.Println("No tasks recorded.")
-		} else {
-			for _, task := range tasks {
-				fmt.Printf("* %s\n", task)
+		report, err := todos.Standup(lookbackDate, untilDate, now)
+		if err != nil {
+			exit(err)
+		}
+
+		if err := writeReport(report, *format, *output); err != nil {
+			exit(err)
+		}
+
+		// Explicit periods are one-off lookups, not the daily standup
+		if *since == "" && *until == "" {
+			if err := todos.RecordStandup(now); err != nil {
+				exit(err)
 			}
 		}
 
 	case *today:
-		tasks, currentDate := todos.GetTasks(time.Now())
+		tasks, currentDate := todos.GetTasks(time.Now(), *withBlocked)
 
 		// Print the lookback date
 		fmt.Printf("%s:\n", currentDate.Format("2006-01-02"))
@@ -135,6 +246,92 @@ func main() {
 	}
 }
 
+// Wait this long after Ctrl-C for the command to wind down, anything
+// still going by then is stuck outside the database, like on a prompt
+const interruptGrace = 2 * time.Second
+
+// A context cancelled by Ctrl-C. The command gets interruptGrace to notice,
+// a second Ctrl-C or the grace running out ends the process
+func interruptible() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+		time.Sleep(interruptGrace)
+		fmt.Fprintln(os.Stderr, "Interrupted")
+		os.Exit(130)
+	}()
+	return ctx, stop
+}
+
+// Prints err and exits, with the shell's code for Ctrl-C when it cancelled
+// the command
+func exit(err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		fmt.Fprintln(os.Stderr, "Interrupted")
+		os.Exit(130)
+	case errors.Is(err, context.DeadlineExceeded):
+		fmt.Fprintln(os.Stderr, "Gave up on the database, query_timeout_seconds ran out:", err)
+	default:
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+	os.Exit(1)
+}
+
+// Prints a warning and carries on, unless Ctrl-C is what failed
+func warn(msg string, err error) {
+	if errors.Is(err, context.Canceled) {
+		exit(err)
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", msg, err)
+}
+
+// Whether the subcommand in args is one used to get a damaged database back
+func recovering(args []string) bool {
+	return len(args) > 0 && (args[0] == "doctor" || args[0] == "backup")
+}
+
+// Anything that can render itself in one of the report formats
+type renderer interface {
+	Render(format string) (string, error)
+}
+
+// Prints a report, or writes it to path when one is given
+func writeReport(r renderer, format, path string) error {
+	out, err := r.Render(format)
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		fmt.Print(out)
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(out), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Report written to %s\n", path)
+	return nil
+}
+
+// Parses a YYYY-MM-DD date or an RFC3339 timestamp given on the command line.
+// A date stands for the start of that day in loc, or its end when endOfDay is set
+func parseTime(s string, endOfDay bool, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", s, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC3339", s)
+	}
+	if endOfDay {
+		return day.AddDate(0, 0, 1), nil
+	}
+	return day, nil
+}
+
 // getting text input for a Todo name
 func getInput(r io.Reader, args ...string) (string, error) {
 
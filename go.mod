@@ -4,10 +4,12 @@ go 1.22.4
 
 require (
 	github.com/alexeyco/simpletable v1.0.0
+	github.com/mattn/go-runewidth v0.0.16
 	github.com/mattn/go-sqlite3 v1.14.24
+	golang.org/x/term v0.29.0
 )
 
 require (
-	github.com/mattn/go-runewidth v0.0.16 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
+	golang.org/x/sys v0.30.0 // indirect
 )
@@ -0,0 +1,225 @@
+package todo
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexeyco/simpletable"
+)
+
+// Columns of the archive in the order scanTodos expects them. Archived todos
+// are never trashed nor blocked
+const archiveColumns = `
+				id,
+				uid,
+				task,
+				done,
+				created_at,
+				completed_at,
+				recurrence,
+				due_at,
+				status,
+				project,
+				estimate,
+				NULL AS deleted_at,
+				0 AS blocked`
+
+const lastArchiveKey = "last_archive"
+
+// Moves the todos that were done or cancelled before the given time out of
+// todos and into the archive. Their transitions, sessions and history stay
+// where they are. Returns how many were archived
+func (db *DB) ArchiveTodos(before time.Time) (int, error) {
+	// Cancelled todos have no completed_at, they go by when they were added
+	const finished = `
+		SELECT id FROM todos
+		WHERE status IN ('done', 'cancelled')
+			AND deleted_at IS NULL
+			AND COALESCE(completed_at, created_at) < ?`
+
+	ids, err := db.ids(finished, before.UTC())
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	if _, err := db.Backup("archive"); err != nil {
+		return 0, err
+	}
+
+	var n int64
+	err = db.transaction(func(tx *sql.Tx) error {
+		now := utcNow()
+		_, err := tx.Exec(`
+			INSERT INTO archive
+			(id, uid, task, done, created_at, completed_at, recurrence, due_at, status, project, estimate, archived_at)
+			SELECT id, uid, task, done, created_at, completed_at, recurrence, due_at, status, project, estimate, ?
+			FROM todos
+			WHERE id IN (`+finished+`);
+			`, now, before.UTC())
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO history
+			(todo_id, field, old_value, new_value, changed_by, changed_at)
+			SELECT id, 'archived', '', '', ?, ?
+			FROM todos
+			WHERE id IN (`+finished+`);
+			`, db.user, now, before.UTC())
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.Exec(`DELETE FROM todos WHERE id IN (`+finished+`)`, before.UTC())
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	return int(n), err
+}
+
+// Every archived todo, completed or cancelled
+func (db *DB) GetArchivedTodos() ([]item, error) {
+	return db.scanTodos(`
+		SELECT` + archiveColumns + `
+		FROM
+				archive
+		ORDER BY id;
+		`)
+}
+
+// Archived todos completed in (since, until], a zero until leaves the end open
+func (db *DB) GetArchivedCompletedBetween(since, until time.Time) ([]item, error) {
+	if until.IsZero() {
+		until = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return db.scanTodos(`
+		SELECT`+archiveColumns+`
+		FROM
+				archive
+		WHERE
+				done = 1
+				AND completed_at > ?
+				AND completed_at <= ?;
+		`, since.UTC(), until.UTC())
+}
+
+// Archived todos with the text in their task or project, most recent first
+func (db *DB) SearchArchive(text string) ([]item, error) {
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text) + "%"
+	return db.scanTodos(`
+		SELECT`+archiveColumns+`
+		FROM
+				archive
+		WHERE
+				task LIKE ? ESCAPE '\'
+				OR project LIKE ? ESCAPE '\'
+		ORDER BY COALESCE(completed_at, created_at) DESC;
+		`, pattern, pattern)
+}
+
+// Archives everything finished more than olderThan ago
+func (t *Todos) Archive(olderThan time.Duration) (int, error) {
+	return t.db.ArchiveTodos(time.Now().Add(-olderThan))
+}
+
+// Applies the archive_after_days policy from the config, at most once a day.
+// Returns how many todos were archived
+func (t *Todos) AutoArchive(now time.Time) (int, error) {
+	if t.config.ArchiveAfterDays == 0 {
+		return 0, nil
+	}
+
+	last, ok, err := t.db.GetMeta(lastArchiveKey)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		if at, err := time.Parse(time.RFC3339Nano, last); err == nil && now.Sub(at) < 24*time.Hour {
+			return 0, nil
+		}
+	}
+
+	n, err := t.db.ArchiveTodos(now.AddDate(0, 0, -t.config.ArchiveAfterDays))
+	if err != nil {
+		return 0, err
+	}
+	return n, t.db.SetMeta(lastArchiveKey, now.Format(time.RFC3339Nano))
+}
+
+// Completed todos in (since, until], archived ones included. Reports over
+// longer periods go through here
+func (t *Todos) completedBetween(since, until time.Time) ([]item, error) {
+	completed, err := t.db.GetCompletedTodosBetween(since, until)
+	if err != nil {
+		return nil, err
+	}
+	archived, err := t.db.GetArchivedCompletedBetween(since, until)
+	if err != nil {
+		return nil, err
+	}
+	return append(completed, archived...), nil
+}
+
+// Every todo outside the trash, archived ones included
+func (t *Todos) allTodos() ([]item, error) {
+	todos, err := t.db.GetAllTodos()
+	if err != nil {
+		return nil, err
+	}
+	archived, err := t.db.GetArchivedTodos()
+	if err != nil {
+		return nil, err
+	}
+	return append(todos, archived...), nil
+}
+
+// Prints the archived todos matching text
+func (t *Todos) PrintArchive(text string) error {
+	found, err := t.db.SearchArchive(text)
+	if err != nil {
+		return err
+	}
+	if len(found) == 0 {
+		fmt.Println("Nothing in the archive matches.")
+		return nil
+	}
+
+	table := simpletable.New()
+	table.Header = &simpletable.Header{
+		Cells: []*simpletable.Cell{
+			{Align: simpletable.AlignCenter, Text: "ID"},
+			{Align: simpletable.AlignCenter, Text: "Task"},
+			{Align: simpletable.AlignCenter, Text: "Project"},
+			{Align: simpletable.AlignCenter, Text: "Status"},
+			{Align: simpletable.AlignRight, Text: "CompletedAt"},
+		},
+	}
+	for _, item := range found {
+		completed := ""
+		if !item.CompletedAt.IsZero() {
+			completed = item.CompletedAt.Format(time.RFC822)
+		}
+		table.Body.Cells = append(table.Body.Cells, []*simpletable.Cell{
+			{Text: strconv.Itoa(item.ID)},
+			{Text: item.Task},
+			{Text: item.Project},
+			{Text: item.Status},
+			{Text: completed},
+		})
+	}
+	table.SetStyle(simpletable.StyleUnicode)
+	table.Println()
+	return nil
+}
+
+// Drops the archived copies of todos, for an undo that writes them back
+// into todos
+func unarchive(tx *sql.Tx, ids []int) error {
+	_, err := tx.Exec(`DELETE FROM archive WHERE id IN (`+placeholders(len(ids))+`)`, intArgs(ids)...)
+	return err
+}
@@ -0,0 +1,144 @@
+package todo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchive(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+	now := time.Now()
+
+	old := addTestTask(t, db, "Ship the 100% release")
+	recent := addTestTask(t, db, "Write the changelog")
+	pending := addTestTask(t, db, "Plan the next release")
+	cancelled := addTestTask(t, db, "Old idea")
+	completeAt(t, db, old, now.AddDate(0, 0, -100))
+	completeAt(t, db, recent, now.AddDate(0, 0, -2))
+	db.Exec(`UPDATE todos SET status = 'cancelled', created_at = ? WHERE id = ?`, now.AddDate(0, 0, -200).UTC(), cancelled)
+	db.Exec(`UPDATE todos SET project = 'web' WHERE id = ?`, old)
+
+	n, err := todos.Archive(90 * 24 * time.Hour)
+	if err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("Expected 2 todos archived, got %d", n)
+	}
+
+	all, _ := db.GetAllTodos()
+	if len(all) != 2 || all[0].ID == old || all[1].ID == old {
+		t.Errorf("Expected only the recent and pending todos left, got %v", all)
+	}
+	if _, err := db.GetTodo(pending); err != nil {
+		t.Errorf("Expected the pending todo untouched, got %v", err)
+	}
+
+	// Searchable by task or project, with LIKE wildcards taken literally
+	for _, query := range []string{"100%", "WEB", "ship"} {
+		found, err := db.SearchArchive(query)
+		if err != nil {
+			t.Fatalf("SearchArchive failed: %v", err)
+		}
+		if len(found) != 1 || found[0].ID != old || found[0].Project != "web" {
+			t.Errorf("Search %q: expected the shipped todo, got %v", query, found)
+		}
+	}
+	if found, _ := db.SearchArchive("_"); len(found) != 0 {
+		t.Errorf("Expected _ to match literally, got %v", found)
+	}
+
+	// Long range reports still count it
+	report, err := todos.Report(now.AddDate(0, 0, -120), now)
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	if report.Total != 2 {
+		t.Errorf("Expected the archived todo in the report, got %d completed", report.Total)
+	}
+	stats, err := todos.Stats(now, 7, 20)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.LeadTimes != 2 {
+		t.Errorf("Expected lead times of both completed todos, got %v", stats.LeadTimes)
+	}
+
+	history, _ := db.GetHistory(old)
+	if last := history[len(history)-1]; last.Field != "archived" || last.Task != "Ship the 100% release" {
+		t.Errorf("Expected the archiving in the history, got %+v", last)
+	}
+}
+
+func TestAutoArchive(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+	now := time.Now()
+
+	first := addTestTask(t, db, "First")
+	completeAt(t, db, first, now.AddDate(0, 0, -40))
+
+	if n, err := todos.AutoArchive(now); err != nil || n != 0 {
+		t.Fatalf("Expected nothing archived without a policy, got %d (err: %v)", n, err)
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, configFileName), []byte(`{"archive_after_days": 30}`), 0644)
+	config, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	todos.SetConfig(config)
+
+	if n, err := todos.AutoArchive(now); err != nil || n != 1 {
+		t.Fatalf("Expected 1 todo archived, got %d (err: %v)", n, err)
+	}
+
+	// Once a day is enough
+	second := addTestTask(t, db, "Second")
+	completeAt(t, db, second, now.AddDate(0, 0, -40))
+	if n, _ := todos.AutoArchive(now.Add(time.Hour)); n != 0 {
+		t.Errorf("Expected no second run within a day, archived %d", n)
+	}
+	if n, _ := todos.AutoArchive(now.Add(25 * time.Hour)); n != 1 {
+		t.Errorf("Expected the next day to archive again, archived %d", n)
+	}
+
+	os.WriteFile(filepath.Join(dir, configFileName), []byte(`{"archive_after_days": -1}`), 0644)
+	if _, err := LoadConfig(dir); err == nil {
+		t.Error("Expected an error for a negative archive_after_days")
+	}
+}
+
+func TestUndoBringsBackArchived(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+
+	id := addTestTask(t, db, "Finish")
+	if _, err := todos.Complete(id); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if _, err := todos.Archive(0); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+	if _, err := todos.Undo(1); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+
+	item, err := db.GetTodo(id)
+	if err != nil {
+		t.Fatalf("Expected the todo back in the list, got %v", err)
+	}
+	if item.Status != StatusTodo {
+		t.Errorf("Expected it pending again, got %s", item.Status)
+	}
+	if archived, _ := db.GetArchivedTodos(); len(archived) != 0 {
+		t.Errorf("Expected the archive empty, got %v", archived)
+	}
+}
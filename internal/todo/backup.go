@@ -0,0 +1,275 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alexeyco/simpletable"
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	backupDir         = "backups"
+	backupTimeFormat  = "20060102-150405.000"
+	defaultBackupKeep = 10
+	lastBackupKey     = "last_backup"
+)
+
+var ErrNoBackup = errors.New("no such backup")
+
+// A snapshot of the database file in the backups directory. The name says
+// when it was taken and why, like todos-20241018-150405.123-purge.db
+type backup struct {
+	Name    string
+	Reason  string
+	TakenAt time.Time
+	Size    int64
+}
+
+func (db *DB) backupDir() string {
+	if db.path == "" || db.path == ":memory:" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(db.path), backupDir)
+}
+
+// How many backups are kept, older ones are removed as new ones are taken
+func (db *DB) SetBackupKeep(n int) {
+	db.keepBackups = n
+}
+
+// Takes a consistent copy of the database with VACUUM INTO and rotates out
+// the oldest backups. Returns the name of the new one, empty for databases
+// that don't live in a file
+func (db *DB) Backup(reason string) (string, error) {
+	dir := db.backupDir()
+	if dir == "" {
+		return "", nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	// Claim the name first, another process backing up within the same
+	// millisecond moves on to the next one
+	for attempt := 0; ; attempt++ {
+		name := fmt.Sprintf("todos-%s-%s.db", time.Now().UTC().Format(backupTimeFormat), reason)
+		path := filepath.Join(dir, name)
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if errors.Is(err, os.ErrExist) && attempt < busyRetries {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		f.Close()
+
+		// VACUUM INTO writes into an empty file just fine
+		if _, err := db.Exec(`VACUUM INTO ?`, path); err != nil {
+			os.Remove(path)
+			return "", fmt.Errorf("backing up the database: %w", err)
+		}
+		return name, db.rotateBackups()
+	}
+}
+
+func (db *DB) rotateBackups() error {
+	backups, err := db.Backups()
+	if err != nil || db.keepBackups < 1 {
+		return err
+	}
+	for _, b := range backups[min(len(backups), db.keepBackups):] {
+		// Another process may have rotated it out already
+		err := os.Remove(filepath.Join(db.backupDir(), b.Name))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Backups on disk, newest first
+func (db *DB) Backups() ([]backup, error) {
+	dir := db.backupDir()
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backup
+	for _, e := range entries {
+		b, ok := parseBackupName(e.Name())
+		if !ok {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			b.Size = info.Size()
+		}
+		b.TakenAt = b.TakenAt.In(db.location)
+		backups = append(backups, b)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].TakenAt.After(backups[j].TakenAt) })
+	return backups, nil
+}
+
+// Reads the time and reason back out of a backup file name
+func parseBackupName(name string) (backup, bool) {
+	rest, ok := strings.CutPrefix(name, "todos-")
+	if !ok {
+		return backup{}, false
+	}
+	rest, ok = strings.CutSuffix(rest, ".db")
+	if !ok || len(rest) < len(backupTimeFormat)+2 {
+		return backup{}, false
+	}
+	at, err := time.Parse(backupTimeFormat, rest[:len(backupTimeFormat)])
+	if err != nil || rest[len(backupTimeFormat)] != '-' {
+		return backup{}, false
+	}
+	return backup{Name: name, Reason: rest[len(backupTimeFormat)+1:], TakenAt: at}, true
+}
+
+// Replaces the contents of the database with a backup through the online
+// backup API, so other connections see the restored data right away. The
+// current state is backed up first, and a backup from an older version is
+// migrated after
+func (db *DB) RestoreBackup(name string) error {
+	dir := db.backupDir()
+	if dir == "" || name != filepath.Base(name) {
+		return fmt.Errorf("%w: %s", ErrNoBackup, name)
+	}
+	if _, ok := parseBackupName(name); !ok {
+		return fmt.Errorf("%w: %s", ErrNoBackup, name)
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%w: %s", ErrNoBackup, name)
+	}
+
+	if _, err := db.Backup("before-restore"); err != nil {
+		return err
+	}
+
+	src, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := copyDatabase(db.baseContext(), db.DB, src); err != nil {
+		return fmt.Errorf("restoring %s: %w", name, err)
+	}
+	return db.migrate()
+}
+
+// Copies every page of src over dest
+func copyDatabase(ctx context.Context, dest, src *sql.DB) error {
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(d interface{}) error {
+		return srcConn.Raw(func(s interface{}) error {
+			destRaw, ok := d.(*sqlite3.SQLiteConn)
+			srcRaw, ok2 := s.(*sqlite3.SQLiteConn)
+			if !ok || !ok2 {
+				return fmt.Errorf("not a sqlite3 connection")
+			}
+			b, err := destRaw.Backup("main", srcRaw, "main")
+			if err != nil {
+				return err
+			}
+			if _, err := b.Step(-1); err != nil {
+				b.Finish()
+				return err
+			}
+			return b.Finish()
+		})
+	})
+}
+
+// Backs up on the backup_every_hours schedule from the config. Returns the
+// name of the backup taken, if one was due
+func (t *Todos) ScheduledBackup(now time.Time) (string, error) {
+	if t.config.BackupEveryHours == 0 {
+		return "", nil
+	}
+
+	last, ok, err := t.db.GetMeta(lastBackupKey)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		at, err := time.Parse(time.RFC3339Nano, last)
+		if err == nil && now.Sub(at) < time.Duration(t.config.BackupEveryHours)*time.Hour {
+			return "", nil
+		}
+	}
+
+	name, err := t.db.Backup("scheduled")
+	if err != nil || name == "" {
+		return name, err
+	}
+	return name, t.db.SetMeta(lastBackupKey, now.Format(time.RFC3339Nano))
+}
+
+func (t *Todos) Backup() (string, error) {
+	return t.db.Backup("manual")
+}
+
+func (t *Todos) RestoreBackup(name string) error {
+	return t.db.RestoreBackup(name)
+}
+
+func (t *Todos) PrintBackups() error {
+	backups, err := t.db.Backups()
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		fmt.Println("No backups yet.")
+		return nil
+	}
+
+	table := simpletable.New()
+	table.Header = &simpletable.Header{
+		Cells: []*simpletable.Cell{
+			{Align: simpletable.AlignCenter, Text: "Name"},
+			{Align: simpletable.AlignCenter, Text: "Reason"},
+			{Align: simpletable.AlignCenter, Text: "Taken"},
+			{Align: simpletable.AlignCenter, Text: "Size"},
+		},
+	}
+	for _, b := range backups {
+		table.Body.Cells = append(table.Body.Cells, []*simpletable.Cell{
+			{Text: b.Name},
+			{Text: b.Reason},
+			{Text: b.TakenAt.Format("2006-01-02 15:04:05")},
+			{Align: simpletable.AlignRight, Text: fmt.Sprintf("%d KB", (b.Size+1023)/1024)},
+		})
+	}
+	table.SetStyle(simpletable.StyleUnicode)
+	table.Println()
+	return nil
+}
@@ -0,0 +1,212 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+
+	addTestTask(t, db, "Keep me")
+	name, err := db.Backup("manual")
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(db.backupDir(), name)); err != nil {
+		t.Fatalf("Expected the backup on disk: %v", err)
+	}
+
+	// Purging takes a backup before it deletes anything
+	gone := addTestTask(t, db, "Lose me")
+	if err := todos.Delete(gone); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := todos.Purge(0); err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	backups, err := db.Backups()
+	if err != nil {
+		t.Fatalf("Backups failed: %v", err)
+	}
+	if len(backups) != 2 || backups[0].Reason != "purge" || backups[1].Name != name {
+		t.Fatalf("Expected the purge backup next to the manual one, got %+v", backups)
+	}
+
+	if err := db.RestoreBackup(name); err != nil {
+		t.Fatalf("RestoreBackup failed: %v", err)
+	}
+	all, _ := db.GetAllTodos()
+	if len(all) != 1 || all[0].Task != "Keep me" {
+		t.Errorf("Expected only the todo from the backup, got %v", all)
+	}
+
+	// The state before the restore was kept, from there the purged todo can
+	// come back too
+	backups, _ = db.Backups()
+	if backups[0].Reason != "before-restore" {
+		t.Errorf("Expected a backup before restoring, got %+v", backups[0])
+	}
+	if err := db.RestoreBackup(backups[1].Name); err != nil {
+		t.Fatalf("RestoreBackup failed: %v", err)
+	}
+	if trashed, _ := db.GetTrashedTodos(); len(trashed) != 1 || trashed[0].Task != "Lose me" {
+		t.Errorf("Expected the purged todo back in the trash, got %v", trashed)
+	}
+
+	for _, bad := range []string{"nope.db", "../todos.db", "todos-20240101-000000.000-manual.db"} {
+		if err := db.RestoreBackup(bad); !errors.Is(err, ErrNoBackup) {
+			t.Errorf("Expected ErrNoBackup for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestBackupRotation(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	db.SetBackupKeep(3)
+
+	for i := 0; i < 5; i++ {
+		if _, err := db.Backup(fmt.Sprintf("run%d", i)); err != nil {
+			t.Fatalf("Backup failed: %v", err)
+		}
+		// Names go down to the millisecond
+		time.Sleep(2 * time.Millisecond)
+	}
+	backups, err := db.Backups()
+	if err != nil {
+		t.Fatalf("Backups failed: %v", err)
+	}
+	var reasons []string
+	for _, b := range backups {
+		reasons = append(reasons, b.Reason)
+	}
+	if got := strings.Join(reasons, " "); got != "run4 run3 run2" {
+		t.Errorf("Expected the three newest kept, got %s", got)
+	}
+}
+
+// A database file as the first release left it: the original schema, no
+// migrations applied and user_version still 0
+func baselineDB(t *testing.T, seed string) string {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "todos.db")
+	db, err := NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("NewDB failed: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE todos (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				task TEXT NOT NULL,
+				done BOOLEAN NOT NULL DEFAULT 0,
+				created_at DATETIME NOT NULL,
+				completed_at DATETIME
+		);
+		CREATE TABLE dependencies (
+				blocker_id INTEGER NOT NULL REFERENCES todos(id),
+				blocked_id INTEGER NOT NULL REFERENCES todos(id),
+				PRIMARY KEY (blocker_id, blocked_id)
+		);
+		` + seed)
+	if err != nil {
+		t.Fatalf("Creating the baseline schema failed: %v", err)
+	}
+	return dbPath
+}
+
+func TestBackupBeforeFirstMigration(t *testing.T) {
+	dbPath := baselineDB(t, `INSERT INTO todos (task, created_at) VALUES ('From the first release', '2024-01-02 09:00:00+00:00');`)
+
+	db, err := NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("NewDB failed: %v", err)
+	}
+	defer db.Close()
+	if err := db.InitSchema(); err != nil {
+		t.Fatalf("InitSchema failed: %v", err)
+	}
+
+	backups, _ := db.Backups()
+	if len(backups) != 1 || backups[0].Reason != "migration-0" {
+		t.Fatalf("Expected a backup before upgrading the baseline database, got %+v", backups)
+	}
+
+	// The backup holds the todo as it was
+	old, err := NewDB(filepath.Join(db.backupDir(), backups[0].Name))
+	if err != nil {
+		t.Fatalf("Opening the backup failed: %v", err)
+	}
+	defer old.Close()
+	var task string
+	if err := old.QueryRow(`SELECT task FROM todos`).Scan(&task); err != nil || task != "From the first release" {
+		t.Errorf("Expected the todo in the backup, got %q (err: %v)", task, err)
+	}
+}
+
+func TestBackupBeforeMigration(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	addTestTask(t, db, "Worth backing up")
+
+	// Pretend the last migration has not run yet
+	last := len(migrations)
+	if _, err := db.Exec(fmt.Sprintf(`DROP TABLE archive; PRAGMA user_version = %d`, last-1)); err != nil {
+		t.Fatalf("Failed to roll the schema back: %v", err)
+	}
+	if err := db.InitSchema(); err != nil {
+		t.Fatalf("InitSchema failed: %v", err)
+	}
+	backups, _ := db.Backups()
+	if len(backups) != 1 || backups[0].Reason != fmt.Sprintf("migration-%d", last-1) {
+		t.Errorf("Expected a backup before the migration, got %+v", backups)
+	}
+
+	// Nothing to migrate, nothing to back up
+	if err := db.InitSchema(); err != nil {
+		t.Fatalf("InitSchema failed: %v", err)
+	}
+	if backups, _ := db.Backups(); len(backups) != 1 {
+		t.Errorf("Expected no backup without a migration, got %d", len(backups))
+	}
+}
+
+func TestScheduledBackup(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+	now := time.Now()
+
+	name, err := todos.ScheduledBackup(now)
+	if err != nil || name == "" {
+		t.Fatalf("Expected a first scheduled backup, got %q (err: %v)", name, err)
+	}
+	if name, _ := todos.ScheduledBackup(now.Add(time.Hour)); name != "" {
+		t.Errorf("Expected no backup an hour later, got %s", name)
+	}
+	if name, _ := todos.ScheduledBackup(now.Add(25 * time.Hour)); name == "" {
+		t.Error("Expected a backup a day later")
+	}
+}
+
+func TestParseBackupName(t *testing.T) {
+	b, ok := parseBackupName("todos-20241018-150405.123-migration-9.db")
+	if !ok || b.Reason != "migration-9" || !b.TakenAt.Equal(time.Date(2024, 10, 18, 15, 4, 5, 123e6, time.UTC)) {
+		t.Errorf("Unexpected parse %+v (ok: %v)", b, ok)
+	}
+	for _, bad := range []string{"todos.db", "todos-2024-manual.db", "notes-20241018-150405.123-manual.db"} {
+		if _, ok := parseBackupName(bad); ok {
+			t.Errorf("Expected %q to be ignored", bad)
+		}
+	}
+}
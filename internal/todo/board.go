@@ -0,0 +1,154 @@
+package todo
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alexeyco/simpletable"
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/term"
+)
+
+const (
+	defaultTermWidth = 80
+	noProject        = "(no project)"
+)
+
+// Prints pending and recently completed todos as a board, one column per
+// workflow state or per project depending on groupBy
+func (t *Todos) PrintBoard(groupBy string) error {
+	todos, err := t.recentAndPending()
+	if err != nil {
+		return err
+	}
+
+	var columns []string
+	cards := map[string][]item{}
+
+	switch groupBy {
+	case "status":
+		for _, status := range Statuses {
+			// Cancelled todos never show up in recentAndPending
+			if status != StatusCancelled {
+				columns = append(columns, status)
+			}
+		}
+		for _, item := range todos {
+			cards[item.Status] = append(cards[item.Status], item)
+		}
+
+	case "project":
+		for _, item := range todos {
+			project := item.Project
+			if project == "" {
+				project = noProject
+			}
+			if _, ok := cards[project]; !ok {
+				columns = append(columns, project)
+			}
+			cards[project] = append(cards[project], item)
+		}
+		sort.Strings(columns)
+
+	default:
+		return fmt.Errorf("unknown board grouping %q, expected status or project", groupBy)
+	}
+
+	if len(columns) == 0 {
+		fmt.Println("Nothing on the board.")
+		return nil
+	}
+
+	// Every column takes its share of the terminal, minus the borders and padding
+	// simpletable adds around each cell
+	width := (terminalWidth()-1)/len(columns) - 3
+	if width < 10 {
+		width = 10
+	}
+
+	table := simpletable.New()
+	header := &simpletable.Header{}
+	row := []*simpletable.Cell{}
+	for _, column := range columns {
+		title := fmt.Sprintf("%s (%d)", column, len(cards[column]))
+		header.Cells = append(header.Cells, &simpletable.Cell{Align: simpletable.AlignCenter, Text: runewidth.Truncate(title, width, "…")})
+
+		var lines []string
+		for _, item := range cards[column] {
+			style := item.style()
+			for _, line := range wrap(fmt.Sprintf("%d %s", item.ID, item.Task), width) {
+				lines = append(lines, style(runewidth.FillRight(line, width)))
+			}
+		}
+		if len(lines) == 0 {
+			lines = append(lines, strings.Repeat(" ", width))
+		}
+		row = append(row, &simpletable.Cell{Text: strings.Join(lines, "\n")})
+	}
+
+	table.Header = header
+	table.Body = &simpletable.Body{Cells: [][]*simpletable.Cell{row}}
+	table.SetStyle(simpletable.StyleUnicode)
+
+	table.Println()
+	return nil
+}
+
+// Color of a todo in listings: green when done, gray when it cannot move on
+func (i item) style() func(string) string {
+	switch {
+	case i.Done:
+		return green
+	case i.stuck():
+		return gray
+	}
+	return blue
+}
+
+// Word wraps s into lines at most width cells wide, breaking words that do not fit on their own
+func wrap(s string, width int) []string {
+	var lines []string
+	var line string
+
+	for _, word := range strings.Fields(s) {
+		for runewidth.StringWidth(word) > width {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			head := runewidth.Truncate(word, width, "")
+			lines = append(lines, head)
+			word = word[len(head):]
+		}
+
+		switch {
+		case line == "":
+			line = word
+		case runewidth.StringWidth(line)+1+runewidth.StringWidth(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Width of the terminal stdout goes to, then $COLUMNS, falling back to 80 columns
+func terminalWidth() int {
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		return width
+	}
+	// Piped or redirected, most shells don't export COLUMNS but some do
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	return defaultTermWidth
+}
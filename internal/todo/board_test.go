@@ -0,0 +1,34 @@
+package todo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mattn/go-runewidth"
+)
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  []string
+	}{
+		{"short", 10, []string{"short"}},
+		{"fix the login page redirect", 10, []string{"fix the", "login page", "redirect"}},
+		{"supercalifragilistic", 8, []string{"supercal", "ifragili", "stic"}},
+		{"日本語のタスク", 6, []string{"日本語", "のタス", "ク"}},
+		{"", 10, nil},
+	}
+
+	for _, tt := range tests {
+		got := wrap(tt.text, tt.width)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("wrap(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+		}
+		for _, line := range got {
+			if runewidth.StringWidth(line) > tt.width {
+				t.Errorf("wrap(%q, %d) produced line %q wider than %d", tt.text, tt.width, line, tt.width)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package todo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const dayFormat = "2006-01-02"
+
+// Which days count as workdays: the configured days of the week minus holidays
+type Calendar struct {
+	workdays map[time.Weekday]bool
+	holidays map[string]bool
+}
+
+// Builds the calendar from the config, reading the holidays file if there is one
+func (c Config) Calendar() (Calendar, error) {
+	cal := Calendar{
+		workdays: map[time.Weekday]bool{},
+		holidays: map[string]bool{},
+	}
+
+	for _, name := range c.Workdays {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if len(key) > 3 {
+			key = key[:3]
+		}
+		day, ok := weekdayNames[key]
+		if !ok {
+			return cal, fmt.Errorf("unknown workday %q", name)
+		}
+		cal.workdays[day] = true
+	}
+
+	path := c.path(c.HolidaysFile)
+	if path == "" {
+		return cal, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cal, nil
+	}
+	if err != nil {
+		return cal, err
+	}
+	defer f.Close()
+
+	// One date per line, anything after a # is a comment
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if _, err := time.Parse(dayFormat, text); err != nil {
+			return cal, fmt.Errorf("%s:%d: expected a YYYY-MM-DD date, got %q", path, line, text)
+		}
+		cal.holidays[text] = true
+	}
+	return cal, scanner.Err()
+}
+
+func (c Calendar) IsWorkday(day time.Time) bool {
+	return c.workdays[day.Weekday()] && !c.holidays[day.Format(dayFormat)]
+}
+
+// Start of the last workday before the day t falls on. Without any workday
+// configured this is just the day before
+func (c Calendar) PreviousWorkday(t time.Time) time.Time {
+	day := startOfDay(t).AddDate(0, 0, -1)
+	for i := 0; i < 366; i++ {
+		if c.IsWorkday(day) {
+			return day
+		}
+		day = day.AddDate(0, 0, -1)
+	}
+	return startOfDay(t).AddDate(0, 0, -1)
+}
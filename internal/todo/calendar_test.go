@@ -0,0 +1,135 @@
+package todo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, dir, config, holidays string) Config {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, configFileName), []byte(config), 0644); err != nil {
+		t.Fatalf("Writing config failed: %v", err)
+	}
+	if holidays != "" {
+		if err := os.WriteFile(filepath.Join(dir, "holidays.txt"), []byte(holidays), 0644); err != nil {
+			t.Fatalf("Writing holidays failed: %v", err)
+		}
+	}
+	c, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	return c
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("Missing file uses defaults", func(t *testing.T) {
+		c, err := LoadConfig(t.TempDir())
+		if err != nil {
+			t.Fatalf("LoadConfig failed: %v", err)
+		}
+		if c.StandupMode != StandupWorkday || len(c.Workdays) != 5 {
+			t.Errorf("Expected the defaults, got %+v", c)
+		}
+	})
+
+	t.Run("Invalid standup mode", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, configFileName), []byte(`{"standup_mode": "weekly"}`), 0644)
+		if _, err := LoadConfig(dir); err == nil {
+			t.Error("Expected an error for an unknown standup mode")
+		}
+	})
+
+	t.Run("Timezone", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, configFileName), []byte(`{"timezone": "Mars/Olympus_Mons"}`), 0644)
+		if _, err := LoadConfig(dir); err == nil {
+			t.Error("Expected an error for an unknown timezone")
+		}
+
+		c := writeConfig(t, dir, `{"timezone": "Asia/Tokyo"}`, "")
+		loc, err := c.Location()
+		if err != nil || loc.String() != "Asia/Tokyo" {
+			t.Errorf("Expected Asia/Tokyo, got %v (err: %v)", loc, err)
+		}
+	})
+}
+
+func TestPreviousWorkday(t *testing.T) {
+	date := func(d int) time.Time {
+		// September 2024, the 16th is a Monday
+		return time.Date(2024, 9, d, 9, 30, 0, 0, time.UTC)
+	}
+	day := func(d int) time.Time {
+		return time.Date(2024, 9, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	t.Run("Default work week", func(t *testing.T) {
+		cal, _ := DefaultConfig().Calendar()
+		if got := cal.PreviousWorkday(date(16)); !got.Equal(day(13)) {
+			t.Errorf("Monday: expected Friday %v, got %v", day(13), got)
+		}
+		if got := cal.PreviousWorkday(date(18)); !got.Equal(day(17)) {
+			t.Errorf("Wednesday: expected Tuesday %v, got %v", day(17), got)
+		}
+	})
+
+	t.Run("Custom work week with holidays", func(t *testing.T) {
+		// Sunday to Thursday, with the Thursday before off
+		c := writeConfig(t, t.TempDir(),
+			`{"workdays": ["sun", "mon", "tue", "wed", "thu"]}`,
+			"# team offsite\n2024-09-12\n")
+		cal, err := c.Calendar()
+		if err != nil {
+			t.Fatalf("Calendar failed: %v", err)
+		}
+		if got := cal.PreviousWorkday(date(15)); !got.Equal(day(11)) {
+			t.Errorf("Sunday after a Thursday off: expected Wednesday %v, got %v", day(11), got)
+		}
+		if cal.IsWorkday(day(13)) {
+			t.Error("Friday should not be a workday")
+		}
+	})
+
+	t.Run("Bad holidays file", func(t *testing.T) {
+		c := writeConfig(t, t.TempDir(), `{}`, "next friday\n")
+		if _, err := c.Calendar(); err == nil {
+			t.Error("Expected an error for a malformed holiday")
+		}
+	})
+}
+
+func TestStandupSince(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+
+	wednesday := time.Date(2024, 9, 18, 9, 0, 0, 0, time.UTC)
+	since, err := todos.StandupSince(wednesday)
+	if err != nil || !since.Equal(time.Date(2024, 9, 17, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Workday mode: expected Tuesday midnight, got %v (err: %v)", since, err)
+	}
+
+	c := writeConfig(t, t.TempDir(), `{"standup_mode": "last-standup"}`, "")
+	if err := todos.SetConfig(c); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+
+	// Never run before: falls back to the previous workday
+	since, err = todos.StandupSince(wednesday)
+	if err != nil || !since.Equal(time.Date(2024, 9, 17, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("First standup: expected Tuesday midnight, got %v (err: %v)", since, err)
+	}
+
+	lastRun := time.Date(2024, 9, 12, 10, 15, 0, 0, time.UTC)
+	if err := todos.RecordStandup(lastRun); err != nil {
+		t.Fatalf("RecordStandup failed: %v", err)
+	}
+	since, err = todos.StandupSince(wednesday)
+	if err != nil || !since.Equal(lastRun) {
+		t.Errorf("Last-standup mode: expected %v, got %v (err: %v)", lastRun, since, err)
+	}
+}
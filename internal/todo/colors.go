@@ -27,3 +27,19 @@ func blue(s string) string {
 func gray(s string) string {
 	return fmt.Sprintf("%s%s%s", ColorGray, s, ColorDefault)
 }
+
+// Five steps from nothing to a lot, darkest first, for the heatmap. Uses the
+// 256 color palette
+var Gradient = []string{
+	"\x1b[38;5;238m",
+	"\x1b[38;5;22m",
+	"\x1b[38;5;28m",
+	"\x1b[38;5;34m",
+	"\x1b[38;5;46m",
+}
+
+// Colors s with the gradient step for level, clamped to the palette
+func gradient(level int, s string) string {
+	level = min(max(level, 0), len(Gradient)-1)
+	return fmt.Sprintf("%s%s%s", Gradient[level], s, ColorDefault)
+}
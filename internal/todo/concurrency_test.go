@@ -0,0 +1,147 @@
+package todo
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+const (
+	hammerWorkers = 8
+	hammerTodos   = 15
+)
+
+// Adds and completes hammerTodos todos through its own connection to the
+// database, the way a separate shell would
+func hammer(dbPath string, worker int) error {
+	db, err := NewDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := db.InitSchema(); err != nil {
+		return fmt.Errorf("worker %d migrating: %w", worker, err)
+	}
+	todos := NewTodos(db)
+
+	for i := 0; i < hammerTodos; i++ {
+		task := fmt.Sprintf("worker %d todo %d", worker, i)
+		if err := todos.Add(task); err != nil {
+			return fmt.Errorf("adding %q: %w", task, err)
+		}
+		var id int
+		if err := db.QueryRow(`SELECT id FROM todos WHERE task = ?`, task).Scan(&id); err != nil {
+			return fmt.Errorf("finding %q: %w", task, err)
+		}
+		if _, err := todos.Complete(id); err != nil {
+			return fmt.Errorf("completing %q: %w", task, err)
+		}
+	}
+	return nil
+}
+
+// Every todo made it, and the history and journal have an entry for each change
+func checkHammered(t *testing.T, dbPath string, workers int) {
+	t.Helper()
+	db, err := NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("NewDB failed: %v", err)
+	}
+	defer db.Close()
+
+	want := workers * hammerTodos
+	counts := []struct {
+		what  string
+		query string
+		want  int
+	}{
+		{"todos", `SELECT COUNT(*) FROM todos`, want},
+		{"done todos", `SELECT COUNT(*) FROM todos WHERE status = 'done' AND done = 1`, want},
+		{"created entries", `SELECT COUNT(*) FROM history WHERE field = 'created'`, want},
+		{"status entries", `SELECT COUNT(*) FROM history WHERE field = 'status'`, want},
+		{"operations", `SELECT COUNT(*) FROM operations`, 2 * want},
+		{"journaled adds of more than one todo", `SELECT COUNT(*) FROM operations WHERE name = 'add' AND json_array_length(after, '$.Todos') != 1`, 0},
+	}
+	for _, c := range counts {
+		var got int
+		if err := db.QueryRow(c.query).Scan(&got); err != nil {
+			t.Fatalf("Counting %s failed: %v", c.what, err)
+		}
+		if got != c.want {
+			t.Errorf("Expected %d %s, got %d", c.want, c.what, got)
+		}
+	}
+
+	d, err := db.Diagnose()
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+	if !d.healthy() {
+		t.Errorf("Expected a healthy database, got %+v", d)
+	}
+}
+
+func TestConcurrentGoroutines(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "todos.db")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, hammerWorkers)
+	for w := 0; w < hammerWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			errs <- hammer(dbPath, w)
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkHammered(t, dbPath, hammerWorkers)
+}
+
+// Runs as a child of TestConcurrentProcesses
+func TestHammerProcess(t *testing.T) {
+	dbPath := os.Getenv("TODO_HAMMER_DB")
+	if dbPath == "" {
+		t.Skip("only runs as a child of TestConcurrentProcesses")
+	}
+	worker, _ := strconv.Atoi(os.Getenv("TODO_HAMMER_WORKER"))
+	if err := hammer(dbPath, worker); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentProcesses(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts several processes")
+	}
+	dbPath := filepath.Join(t.TempDir(), "todos.db")
+
+	cmds := make([]*exec.Cmd, hammerWorkers)
+	outs := make([]bytes.Buffer, hammerWorkers)
+	for w := range cmds {
+		cmds[w] = exec.Command(os.Args[0], "-test.run=^TestHammerProcess$")
+		cmds[w].Env = append(os.Environ(), "TODO_HAMMER_DB="+dbPath, "TODO_HAMMER_WORKER="+strconv.Itoa(w))
+		cmds[w].Stdout, cmds[w].Stderr = &outs[w], &outs[w]
+		if err := cmds[w].Start(); err != nil {
+			t.Fatalf("Starting worker %d failed: %v", w, err)
+		}
+	}
+	for w, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Errorf("Worker %d failed: %v\n%s", w, err, outs[w].String())
+		}
+	}
+
+	checkHammered(t, dbPath, hammerWorkers)
+}
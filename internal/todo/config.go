@@ -0,0 +1,126 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const configFileName = "config.json"
+
+// Standup modes
+const (
+	// Look back to the start of the previous workday
+	StandupWorkday = "workday"
+	// Look back to the last time the standup was printed
+	StandupLastRun = "last-standup"
+)
+
+// Settings read from config.json next to the database. Every field is
+// optional, see DefaultConfig for what is used when it is left out
+type Config struct {
+	// Days of the week worked on, as three letter names (mon, tue, ...)
+	Workdays []string `json:"workdays"`
+	// File listing days off, one YYYY-MM-DD per line. Relative paths are
+	// resolved against the config directory
+	HolidaysFile string `json:"holidays_file"`
+	// StandupWorkday or StandupLastRun
+	StandupMode string `json:"standup_mode"`
+	// IANA name of the timezone days start and end in, like Europe/Berlin.
+	// The system timezone when left empty
+	Timezone string `json:"timezone"`
+	// Pomodoro lengths used by focus
+	FocusMinutes int `json:"focus_minutes"`
+	BreakMinutes int `json:"break_minutes"`
+	// Todos done or cancelled longer ago than this are archived
+	// automatically. 0 leaves archiving to the archive command
+	ArchiveAfterDays int `json:"archive_after_days"`
+	// Hours between automatic backups, 0 turns them off
+	BackupEveryHours int `json:"backup_every_hours"`
+	// How many backups are kept
+	BackupKeep int `json:"backup_keep"`
+	// Seconds a single query may take before it is given up on, 0 for no limit
+	QueryTimeoutSeconds int `json:"query_timeout_seconds"`
+
+	dir string
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Workdays:         []string{"mon", "tue", "wed", "thu", "fri"},
+		HolidaysFile:     "holidays.txt",
+		StandupMode:      StandupWorkday,
+		FocusMinutes:     25,
+		BreakMinutes:     5,
+		BackupEveryHours: 24,
+		BackupKeep:       10,
+		// Generous, it is there to stop a query that hangs
+		QueryTimeoutSeconds: 30,
+	}
+}
+
+// Loads config.json from dir, a missing file just means the defaults
+func LoadConfig(dir string) (Config, error) {
+	config := DefaultConfig()
+	config.dir = dir
+
+	data, err := os.ReadFile(filepath.Join(dir, configFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("reading %s: %w", configFileName, err)
+	}
+
+	if _, err := config.Location(); err != nil {
+		return config, err
+	}
+
+	if config.FocusMinutes < 1 || config.BreakMinutes < 0 {
+		return config, fmt.Errorf("focus_minutes needs to be at least 1 and break_minutes can't be negative")
+	}
+
+	if config.ArchiveAfterDays < 0 || config.BackupEveryHours < 0 || config.QueryTimeoutSeconds < 0 {
+		return config, fmt.Errorf("archive_after_days, backup_every_hours and query_timeout_seconds can't be negative")
+	}
+	if config.BackupKeep < 1 {
+		return config, fmt.Errorf("backup_keep needs to be at least 1")
+	}
+
+	switch config.StandupMode {
+	case StandupWorkday, StandupLastRun:
+	default:
+		return config, fmt.Errorf("unknown standup_mode %q, expected %s or %s", config.StandupMode, StandupWorkday, StandupLastRun)
+	}
+	return config, nil
+}
+
+func (c Config) Location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q: %w", c.Timezone, err)
+	}
+	return loc, nil
+}
+
+// Resolves a path from the config against the config directory. Relative
+// paths mean nothing for a config that was not loaded from disk
+func (c Config) path(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	if c.dir == "" {
+		return ""
+	}
+	return filepath.Join(c.dir, p)
+}
@@ -0,0 +1,283 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Returns a DB running every statement under ctx, so cancelling it stops
+// whatever is in flight. It shares the connection with db
+func (db *DB) WithContext(ctx context.Context) *DB {
+	c := *db
+	c.ctx = ctx
+	return &c
+}
+
+// How long a single statement or transaction may take, 0 for no limit
+func (db *DB) SetQueryTimeout(d time.Duration) {
+	db.timeout = d
+}
+
+func (db *DB) baseContext() context.Context {
+	if db.ctx == nil {
+		return context.Background()
+	}
+	return db.ctx
+}
+
+// Context for one statement or transaction, the query timeout applied
+func (db *DB) statementContext() (context.Context, context.CancelFunc) {
+	if db.timeout <= 0 {
+		return db.baseContext(), func() {}
+	}
+	return context.WithTimeout(db.baseContext(), db.timeout)
+}
+
+// Exec, Query and QueryRow take over from the ones of *sql.DB, so every
+// method goes through the context
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := db.statementContext()
+	defer cancel()
+	return db.DB.ExecContext(ctx, query, args...)
+}
+
+func (db *DB) Query(query string, args ...interface{}) (*rows, error) {
+	ctx, cancel := db.statementContext()
+	r, err := db.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &rows{Rows: r, cancel: cancel}, nil
+}
+
+func (db *DB) QueryRow(query string, args ...interface{}) *row {
+	ctx, cancel := db.statementContext()
+	return &row{Row: db.DB.QueryRowContext(ctx, query, args...), cancel: cancel}
+}
+
+// Rows from DB.Query, closing them is done with the statement's context too
+type rows struct {
+	*sql.Rows
+	cancel context.CancelFunc
+}
+
+func (r *rows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+// Row from DB.QueryRow, the statement is over once it is scanned
+type row struct {
+	*sql.Row
+	cancel context.CancelFunc
+}
+
+func (r *row) Scan(dest ...interface{}) error {
+	defer r.cancel()
+	return r.Row.Scan(dest...)
+}
+
+// Runs Todos methods under ctx, see DB.WithContext
+func (t *Todos) WithContext(ctx context.Context) *Todos {
+	c := *t
+	c.db = t.db.WithContext(ctx)
+	return &c
+}
+
+// The context Todos methods run under
+func (t *Todos) Context() context.Context {
+	return t.db.baseContext()
+}
+
+// Context taking variants of the DB methods, for callers that want to cancel
+// them or give them a deadline of their own
+
+func (db *DB) InitSchemaContext(ctx context.Context) error {
+	return db.WithContext(ctx).InitSchema()
+}
+
+func (db *DB) AddTodoContext(ctx context.Context, task string) error {
+	return db.WithContext(ctx).AddTodo(task)
+}
+
+func (db *DB) AddRecurringTodoContext(ctx context.Context, task string, rule Recurrence, due time.Time) error {
+	return db.WithContext(ctx).AddRecurringTodo(task, rule, due)
+}
+
+func (db *DB) UpdateTaskContext(ctx context.Context, id int, task string) error {
+	return db.WithContext(ctx).UpdateTask(id, task)
+}
+
+func (db *DB) SetProjectContext(ctx context.Context, id int, project string) error {
+	return db.WithContext(ctx).SetProject(id, project)
+}
+
+func (db *DB) SetEstimateContext(ctx context.Context, id int, estimate Estimate) error {
+	return db.WithContext(ctx).SetEstimate(id, estimate)
+}
+
+func (db *DB) CompleteTodoContext(ctx context.Context, id int) error {
+	return db.WithContext(ctx).CompleteTodo(id)
+}
+
+func (db *DB) SetStatusContext(ctx context.Context, id int, status string) error {
+	return db.WithContext(ctx).SetStatus(id, status)
+}
+
+func (db *DB) DeleteTodoContext(ctx context.Context, id int) error {
+	return db.WithContext(ctx).DeleteTodo(id)
+}
+
+func (db *DB) RestoreTodoContext(ctx context.Context, id int) error {
+	return db.WithContext(ctx).RestoreTodo(id)
+}
+
+func (db *DB) PurgeTodosContext(ctx context.Context, before time.Time) (int, error) {
+	return db.WithContext(ctx).PurgeTodos(before)
+}
+
+func (db *DB) GetTodoContext(ctx context.Context, id int) (item, error) {
+	return db.WithContext(ctx).GetTodo(id)
+}
+
+func (db *DB) GetAllTodosContext(ctx context.Context) ([]item, error) {
+	return db.WithContext(ctx).GetAllTodos()
+}
+
+func (db *DB) GetCompletedTodosContext(ctx context.Context, since time.Time) ([]item, error) {
+	return db.WithContext(ctx).GetCompletedTodos(since)
+}
+
+func (db *DB) GetCompletedTodosBetweenContext(ctx context.Context, since, until time.Time) ([]item, error) {
+	return db.WithContext(ctx).GetCompletedTodosBetween(since, until)
+}
+
+func (db *DB) GetPendingTodosContext(ctx context.Context) ([]item, error) {
+	return db.WithContext(ctx).GetPendingTodos()
+}
+
+func (db *DB) GetRecentOrPendingTodosContext(ctx context.Context, since time.Time) ([]item, error) {
+	return db.WithContext(ctx).GetRecentOrPendingTodos(since)
+}
+
+func (db *DB) GetTodosByStatusContext(ctx context.Context, status string) ([]item, error) {
+	return db.WithContext(ctx).GetTodosByStatus(status)
+}
+
+func (db *DB) GetTrashedTodosContext(ctx context.Context) ([]item, error) {
+	return db.WithContext(ctx).GetTrashedTodos()
+}
+
+func (db *DB) GetMetaContext(ctx context.Context, key string) (string, bool, error) {
+	return db.WithContext(ctx).GetMeta(key)
+}
+
+func (db *DB) SetMetaContext(ctx context.Context, key, value string) error {
+	return db.WithContext(ctx).SetMeta(key, value)
+}
+
+func (db *DB) AddDependencyContext(ctx context.Context, blockerID, blockedID int) error {
+	return db.WithContext(ctx).AddDependency(blockerID, blockedID)
+}
+
+func (db *DB) RemoveDependencyContext(ctx context.Context, blockerID, blockedID int) error {
+	return db.WithContext(ctx).RemoveDependency(blockerID, blockedID)
+}
+
+func (db *DB) GetDependentsContext(ctx context.Context, id int) ([]item, error) {
+	return db.WithContext(ctx).GetDependents(id)
+}
+
+func (db *DB) GetBlockersContext(ctx context.Context, id int) ([]item, error) {
+	return db.WithContext(ctx).GetBlockers(id)
+}
+
+func (db *DB) HandlesContext(ctx context.Context) (map[int]string, error) {
+	return db.WithContext(ctx).Handles()
+}
+
+func (db *DB) FindByUIDPrefixContext(ctx context.Context, prefix string) ([]int, error) {
+	return db.WithContext(ctx).FindByUIDPrefix(prefix)
+}
+
+func (db *DB) GetTransitionsContext(ctx context.Context, id int) ([]transition, error) {
+	return db.WithContext(ctx).GetTransitions(id)
+}
+
+func (db *DB) GetAllTransitionsContext(ctx context.Context) ([]transition, error) {
+	return db.WithContext(ctx).GetAllTransitions()
+}
+
+func (db *DB) StartTimerContext(ctx context.Context, id int) error {
+	return db.WithContext(ctx).StartTimer(id)
+}
+
+func (db *DB) StopTimerContext(ctx context.Context) (session, error) {
+	return db.WithContext(ctx).StopTimer()
+}
+
+func (db *DB) RunningTimerContext(ctx context.Context) (session, bool, error) {
+	return db.WithContext(ctx).RunningTimer()
+}
+
+func (db *DB) GetSessionsContext(ctx context.Context, since, until time.Time) ([]session, error) {
+	return db.WithContext(ctx).GetSessions(since, until)
+}
+
+func (db *DB) TimeSpentContext(ctx context.Context, since, until time.Time) (map[int]time.Duration, error) {
+	return db.WithContext(ctx).TimeSpent(since, until)
+}
+
+func (db *DB) FocusCountContext(ctx context.Context, id int) (int, error) {
+	return db.WithContext(ctx).FocusCount(id)
+}
+
+func (db *DB) GetHistoryContext(ctx context.Context, id int) ([]change, error) {
+	return db.WithContext(ctx).GetHistory(id)
+}
+
+func (db *DB) GetChangesContext(ctx context.Context, since, until time.Time) ([]change, error) {
+	return db.WithContext(ctx).GetChanges(since, until)
+}
+
+func (db *DB) UndoOperationsContext(ctx context.Context, n int) ([]operation, error) {
+	return db.WithContext(ctx).UndoOperations(n)
+}
+
+func (db *DB) RedoOperationsContext(ctx context.Context, n int) ([]operation, error) {
+	return db.WithContext(ctx).RedoOperations(n)
+}
+
+func (db *DB) ArchiveTodosContext(ctx context.Context, before time.Time) (int, error) {
+	return db.WithContext(ctx).ArchiveTodos(before)
+}
+
+func (db *DB) GetArchivedTodosContext(ctx context.Context) ([]item, error) {
+	return db.WithContext(ctx).GetArchivedTodos()
+}
+
+func (db *DB) GetArchivedCompletedBetweenContext(ctx context.Context, since, until time.Time) ([]item, error) {
+	return db.WithContext(ctx).GetArchivedCompletedBetween(since, until)
+}
+
+func (db *DB) SearchArchiveContext(ctx context.Context, text string) ([]item, error) {
+	return db.WithContext(ctx).SearchArchive(text)
+}
+
+func (db *DB) BackupContext(ctx context.Context, reason string) (string, error) {
+	return db.WithContext(ctx).Backup(reason)
+}
+
+func (db *DB) RestoreBackupContext(ctx context.Context, name string) error {
+	return db.WithContext(ctx).RestoreBackup(name)
+}
+
+func (db *DB) DiagnoseContext(ctx context.Context) (diagnosis, error) {
+	return db.WithContext(ctx).Diagnose()
+}
+
+func (db *DB) RepairContext(ctx context.Context, d diagnosis) (int, error) {
+	return db.WithContext(ctx).Repair(d)
+}
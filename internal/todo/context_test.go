@@ -0,0 +1,143 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCancelledContext(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := db.AddTodoContext(ctx, "Never added"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected AddTodoContext to be cancelled, got %v", err)
+	}
+	if _, err := db.GetAllTodosContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected GetAllTodosContext to be cancelled, got %v", err)
+	}
+	if err := NewTodos(db).WithContext(ctx).Add("Never added either"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Todos.Add to be cancelled, got %v", err)
+	}
+
+	// The DB itself is untouched by the cancelled copies
+	todos, err := db.GetAllTodos()
+	if err != nil {
+		t.Fatalf("GetAllTodos failed: %v", err)
+	}
+	if len(todos) != 0 {
+		t.Errorf("Expected nothing added, got %+v", todos)
+	}
+	if err := db.AddTodoContext(context.Background(), "Added"); err != nil {
+		t.Errorf("AddTodoContext failed: %v", err)
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// Counts forever, only the timeout stops it
+	const endless = `
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n)
+		SELECT COUNT(*) FROM n;`
+
+	db.SetQueryTimeout(50 * time.Millisecond)
+	start := time.Now()
+	var count int
+	err := db.QueryRow(endless).Scan(&count)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the query to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the query stopped around the timeout, took %v", elapsed)
+	}
+
+	// Each statement gets its own timeout, quick ones carry on
+	for i := 0; i < 3; i++ {
+		if err := db.AddTodo("Quick"); err != nil {
+			t.Fatalf("AddTodo failed after a timeout: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// Cancelling stops a query before its timeout does
+	db.SetQueryTimeout(time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := db.WithContext(ctx).QueryRow(endless).Scan(&count); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the query to be cancelled, got %v", err)
+	}
+}
+
+func TestQueryTimeoutConfig(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+
+	config := DefaultConfig()
+	config.QueryTimeoutSeconds = 7
+	if err := todos.SetConfig(config); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	if db.timeout != 7*time.Second {
+		t.Errorf("Expected a 7s query timeout, got %v", db.timeout)
+	}
+	if todos.WithContext(context.Background()).db.timeout != 7*time.Second {
+		t.Errorf("Expected the timeout to carry over to WithContext")
+	}
+
+	if c := writeConfig(t, t.TempDir(), `{}`, ""); c.QueryTimeoutSeconds != 30 {
+		t.Errorf("Expected a 30s default, got %d", c.QueryTimeoutSeconds)
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, configFileName), []byte(`{"query_timeout_seconds": -1}`), 0644)
+	if _, err := LoadConfig(dir); err == nil {
+		t.Error("Expected an error for a negative query_timeout_seconds")
+	}
+}
+
+func TestStatementContextReleased(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	db.SetQueryTimeout(time.Minute)
+	addTestTask(t, db, "Something to select")
+
+	released := func(cancel *context.CancelFunc) *bool {
+		called := false
+		next := *cancel
+		*cancel = func() {
+			called = true
+			next()
+		}
+		return &called
+	}
+
+	rows, err := db.Query(`SELECT id FROM todos`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	closed := released(&rows.cancel)
+	for rows.Next() {
+	}
+	rows.Close()
+	if !*closed {
+		t.Error("Expected closing the rows to release their context")
+	}
+
+	row := db.QueryRow(`SELECT COUNT(*) FROM todos`)
+	scanned := released(&row.cancel)
+	var n int
+	if err := row.Scan(&n); err != nil || n != 1 {
+		t.Fatalf("Scan failed: %d, %v", n, err)
+	}
+	if !*scanned {
+		t.Error("Expected scanning the row to release its context")
+	}
+}
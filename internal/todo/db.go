@@ -1,18 +1,64 @@
 package todo
 
 import (
+	"context"
 	"database/sql"
-	_ "github.com/mattn/go-sqlite3"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/mattn/go-sqlite3"
 )
 
+// Timestamps are stored in UTC. location is the timezone day boundaries are
+// computed in, and the one todos are handed out in
 type DB struct {
 	*sql.DB
+	location *time.Location
+	// Who changes are recorded as in the history
+	user string
+	// Backups go next to the database file, see Backup
+	path        string
+	keepBackups int
+	// Set while a change is journaled for undo, see Todos.journal
+	operation *pendingOperation
+	// Statements run under ctx and take at most timeout, see WithContext
+	ctx     context.Context
+	timeout time.Duration
 }
 
+// Columns selected for every item, in the order scanTodos expects them.
+// blocked is computed: a todo is blocked while any of its blockers is still pending
+const todoColumns = `
+				id,
+				uid,
+				task,
+				done,
+				created_at,
+				completed_at,
+				recurrence,
+				due_at,
+				status,
+				project,
+				estimate,
+				deleted_at,
+				EXISTS (
+					SELECT 1 FROM dependencies d
+					JOIN todos b ON b.id = d.blocker_id
+					WHERE d.blocked_id = todos.id AND b.status NOT IN ('done', 'cancelled') AND b.deleted_at IS NULL
+				) AS blocked`
+
+// How long a write waits on another connection holding the lock before
+// SQLite gives up with SQLITE_BUSY
+const busyTimeout = 5 * time.Second
+
+// Attempts at a transaction that keeps coming back busy, see transaction
+const busyRetries = 5
+
 func NewDB(dbPath string) (*DB, error) {
 	// We open the db and return the error if one rises
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open("sqlite3", dataSource(dbPath))
 	if err != nil {
 		return nil, err
 	}
@@ -24,7 +70,62 @@ func NewDB(dbPath string) (*DB, error) {
 	}
 
 	// If everythign goes well, returnt eh DB object and null fro error
-	return &DB{DB: db}, nil
+	return &DB{DB: db, location: time.Local, user: currentUser(), path: dbPath, keepBackups: defaultBackupKeep}, nil
+}
+
+// Several shells and cron jobs share the same file. WAL lets them read while
+// someone writes, the busy timeout makes a writer wait its turn instead of
+// failing, and immediate transactions take the write lock up front so two of
+// them can't deadlock trying to upgrade a read lock
+func dataSource(dbPath string) string {
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%s_journal_mode=WAL&_busy_timeout=%d&_txlock=immediate",
+		dbPath, sep, busyTimeout.Milliseconds())
+}
+
+// SQLite still answers busy once the timeout runs out, or right away when
+// waiting could deadlock. Either way the transaction can be tried again
+func isBusy(err error) bool {
+	var e sqlite3.Error
+	return errors.As(err, &e) && (e.Code == sqlite3.ErrBusy || e.Code == sqlite3.ErrLocked)
+}
+
+// Runs fn in a transaction and commits it, starting over with a growing
+// pause while the database is busy. fn may run more than once
+func (db *DB) transaction(fn func(tx *sql.Tx) error) error {
+	var err error
+	for attempt := 0; attempt < busyRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt*attempt) * 50 * time.Millisecond):
+			case <-db.baseContext().Done():
+				return db.baseContext().Err()
+			}
+		}
+		if err = db.transactionOnce(fn); !isBusy(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// The query timeout covers the transaction as a whole
+func (db *DB) transactionOnce(fn func(tx *sql.Tx) error) error {
+	ctx, cancel := db.statementContext()
+	defer cancel()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // Creating the Schema of our DB
@@ -36,35 +137,285 @@ func (db *DB) InitSchema() error {
 					done BOOLEAN NOT NULL DEFAULT 0,
 					created_at DATETIME NOT NULL,
 					completed_at DATETIME
-			)
+			);
+
+			CREATE TABLE IF NOT EXISTS dependencies (
+					blocker_id INTEGER NOT NULL REFERENCES todos(id),
+					blocked_id INTEGER NOT NULL REFERENCES todos(id),
+					PRIMARY KEY (blocker_id, blocked_id)
+			);
 		`)
-	return err
+	if err != nil {
+		return err
+	}
+
+	return db.migrate()
 }
 
-func (db *DB) AddTodo(task string) error {
-	_, err := db.Exec(`
+// Schema changes made after the first release, in order. PRAGMA user_version
+// records how many of them have been applied to the database file
+var migrations = []string{
+	`ALTER TABLE todos ADD COLUMN recurrence TEXT NOT NULL DEFAULT '';
+	 ALTER TABLE todos ADD COLUMN due_at DATETIME;`,
+
+	`ALTER TABLE todos ADD COLUMN status TEXT NOT NULL DEFAULT 'todo';
+	 UPDATE todos SET status = 'done' WHERE done = 1;
+	 CREATE TABLE status_transitions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			todo_id INTEGER NOT NULL REFERENCES todos(id),
+			from_status TEXT NOT NULL,
+			to_status TEXT NOT NULL,
+			changed_at DATETIME NOT NULL
+	 );
+	 INSERT INTO status_transitions (todo_id, from_status, to_status, changed_at)
+	 SELECT id, 'todo', 'done', completed_at FROM todos WHERE done = 1 AND completed_at IS NOT NULL;`,
+
+	`ALTER TABLE todos ADD COLUMN project TEXT NOT NULL DEFAULT '';`,
+
+	// Existing rows get a random version 4 UUID, same as newUID
+	`ALTER TABLE todos ADD COLUMN uid TEXT;
+	 UPDATE todos SET uid =
+			lower(hex(randomblob(4))) || '-' ||
+			lower(hex(randomblob(2))) || '-4' ||
+			substr(lower(hex(randomblob(2))), 2) || '-' ||
+			substr('89ab', 1 + abs(random()) % 4, 1) || substr(lower(hex(randomblob(2))), 2) || '-' ||
+			lower(hex(randomblob(6)));
+	 CREATE UNIQUE INDEX todos_uid ON todos(uid);`,
+
+	`CREATE TABLE meta (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+	 );`,
+
+	// Timestamps used to be stored in whatever zone the process had
+	`UPDATE todos SET created_at = strftime('%Y-%m-%d %H:%M:%f+00:00', created_at) WHERE created_at NOT LIKE '%+00:00';
+	 UPDATE todos SET completed_at = strftime('%Y-%m-%d %H:%M:%f+00:00', completed_at) WHERE completed_at NOT LIKE '%+00:00';
+	 UPDATE todos SET due_at = strftime('%Y-%m-%d %H:%M:%f+00:00', due_at) WHERE due_at NOT LIKE '%+00:00';
+	 UPDATE status_transitions SET changed_at = strftime('%Y-%m-%d %H:%M:%f+00:00', changed_at) WHERE changed_at NOT LIKE '%+00:00';`,
+
+	// Time tracking. The partial index allows a single session without an end
+	`CREATE TABLE sessions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			todo_id INTEGER NOT NULL REFERENCES todos(id),
+			started_at DATETIME NOT NULL,
+			ended_at DATETIME
+	 );
+	 CREATE INDEX sessions_todo ON sessions(todo_id);
+	 CREATE UNIQUE INDEX sessions_running ON sessions((ended_at IS NULL)) WHERE ended_at IS NULL;`,
+
+	`ALTER TABLE sessions ADD COLUMN kind TEXT NOT NULL DEFAULT 'timer';`,
+
+	`ALTER TABLE todos ADD COLUMN estimate TEXT NOT NULL DEFAULT '';`,
+
+	// Deleted todos go to the trash first, see DeleteTodo and PurgeTodos
+	`ALTER TABLE todos ADD COLUMN deleted_at DATETIME;`,
+
+	// Undo journal, before and after are JSON snapshots of what an operation touched
+	`CREATE TABLE operations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			summary TEXT NOT NULL,
+			before TEXT NOT NULL,
+			after TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			undone_at DATETIME
+	 );`,
+
+	// Field level history of every todo. Rows can be added but never changed
+	`CREATE TABLE history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			todo_id INTEGER NOT NULL,
+			field TEXT NOT NULL,
+			old_value TEXT NOT NULL,
+			new_value TEXT NOT NULL,
+			changed_by TEXT NOT NULL,
+			changed_at DATETIME NOT NULL
+	 );
+	 CREATE INDEX history_todo ON history(todo_id);
+	 CREATE INDEX history_changed_at ON history(changed_at);
+	 CREATE TRIGGER history_no_update BEFORE UPDATE ON history
+	 BEGIN SELECT RAISE(ABORT, 'history is append-only'); END;
+	 CREATE TRIGGER history_no_delete BEFORE DELETE ON history
+	 BEGIN SELECT RAISE(ABORT, 'history is append-only'); END;`,
+
+	// Finished todos moved out of the way, see ArchiveTodos
+	`CREATE TABLE archive (
+			id INTEGER PRIMARY KEY,
+			uid TEXT,
+			task TEXT NOT NULL,
+			done BOOLEAN NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL,
+			completed_at DATETIME,
+			recurrence TEXT NOT NULL DEFAULT '',
+			due_at DATETIME,
+			status TEXT NOT NULL DEFAULT 'todo',
+			project TEXT NOT NULL DEFAULT '',
+			estimate TEXT NOT NULL DEFAULT '',
+			archived_at DATETIME NOT NULL
+	 );
+	 CREATE INDEX archive_completed_at ON archive(completed_at);`,
+}
+
+// Sets the timezone used for day boundaries, time.Local by default
+func (db *DB) SetLocation(loc *time.Location) {
+	db.location = loc
+}
+
+// Current time in UTC, the way every timestamp is stored
+func utcNow() time.Time {
+	return time.Now().UTC()
+}
+
+func (db *DB) migrate() error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+
+	// A database with data in it gets backed up before its schema changes.
+	// Databases from before the migrations hold their todos at version 0
+	var hasTodos bool
+	err := db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'todos')
+		`).Scan(&hasTodos)
+	if err != nil {
+		return err
+	}
+	if hasTodos {
+		if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM todos)`).Scan(&hasTodos); err != nil {
+			return err
+		}
+	}
+	if hasTodos && version < len(migrations) {
+		if _, err := db.Backup(fmt.Sprintf("migration-%d", version)); err != nil {
+			return err
+		}
+	}
+
+	for version < len(migrations) {
+		err := db.transaction(func(tx *sql.Tx) error {
+			// Read again under the write lock, another process may have
+			// migrated in the meantime
+			if err := tx.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+				return err
+			}
+			if version >= len(migrations) {
+				return nil
+			}
+			if _, err := tx.Exec(migrations[version]); err != nil {
+				return fmt.Errorf("migration %d: %w", version+1, err)
+			}
+			// PRAGMA does not take bound parameters
+			_, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version+1))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		version++
+	}
+	return nil
+}
+
+// Both *sql.DB and *sql.Tx, so inserts can run inside a transaction or not
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Inserts a new todo from the attributes set on i and returns its ID
+func insertTodo(e execer, i item) (int, error) {
+	var due sql.NullTime
+	if !i.DueAt.IsZero() {
+		due = sql.NullTime{Time: i.DueAt.UTC(), Valid: true}
+	}
+
+	if i.UID == "" {
+		i.UID = newUID()
+	}
+
+	res, err := e.Exec(`
 				INSERT INTO todos
-				(task, created_at) VALUES (?, ?)
-		`, task, time.Now())
+				(uid, task, created_at, recurrence, due_at, project, estimate) VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, i.UID, i.Task, utcNow(), i.Recurrence, due, i.Project, i.Estimate)
+	if err != nil {
+		return 0, err
+	}
 
-	return err
+	id, err := res.LastInsertId()
+	return int(id), err
 }
 
-func (db *DB) CompleteTodo(id int) error {
-	_, err := db.Exec(`
-			UPDATE todos 
-			SET done = 1, completed_at = ?
-			WHERE id = ?
-		`, time.Now(), id)
+func (db *DB) AddTodo(task string) error {
+	_, err := db.addTodo(item{Task: task})
+	return err
+}
 
+// Adds a todo that comes back on the given schedule, first due on `due`
+func (db *DB) AddRecurringTodo(task string, rule Recurrence, due time.Time) error {
+	_, err := db.addTodo(item{Task: task, Recurrence: rule.Anchor(due).String(), DueAt: due})
 	return err
 }
 
+// Inserts a todo and records it in the history
+func (db *DB) addTodo(i item) (int, error) {
+	var id int
+	err := db.tracked(nil, func(tx *sql.Tx) error {
+		var err error
+		id, err = insertTodo(tx, i)
+		return err
+	})
+	return id, err
+}
+
+func (db *DB) UpdateTask(id int, task string) error {
+	return db.tracked([]int{id}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+				UPDATE todos
+				SET task = ?
+				WHERE id = ? AND deleted_at IS NULL
+			`, task, id)
+		return err
+	})
+}
+
+func (db *DB) SetProject(id int, project string) error {
+	return db.tracked([]int{id}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+				UPDATE todos
+				SET project = ?
+				WHERE id = ? AND deleted_at IS NULL
+			`, project, id)
+		return err
+	})
+}
+
+func (db *DB) SetEstimate(id int, estimate Estimate) error {
+	return db.tracked([]int{id}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+				UPDATE todos
+				SET estimate = ?
+				WHERE id = ? AND deleted_at IS NULL
+			`, estimate.String(), id)
+		return err
+	})
+}
+
+// Completing a recurring todo also adds its next occurrence
+func (db *DB) CompleteTodo(id int) error {
+	return db.SetStatus(id, StatusDone)
+}
+
+// Moves a todo to the trash. It disappears from every query but keeps its
+// history and dependencies until it is restored or purged
 func (db *DB) DeleteTodo(id int) error {
-	_, err := db.Exec(`
-		DELETE FROM todos WHERE id = ?
-		`, id)
-	return err
+	now := utcNow()
+	return db.tracked([]int{id}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			UPDATE sessions SET ended_at = ? WHERE todo_id = ? AND ended_at IS NULL;
+			UPDATE todos SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL;
+			`, now, id, now, id)
+		return err
+	})
 }
 
 // THis helper function allows to pass any datatype into the query parameters by assigning it the interface type
@@ -78,13 +429,20 @@ func (db *DB) scanTodos(query string, args ...interface{}) ([]item, error) {
 	var todos []item
 	for rows.Next() {
 		var i item
-		var completedAt sql.NullTime
-		err := rows.Scan(&i.ID, &i.Task, &i.Done, &i.CreatedAt, &completedAt)
+		var completedAt, dueAt, deletedAt sql.NullTime
+		err := rows.Scan(&i.ID, &i.UID, &i.Task, &i.Done, &i.CreatedAt, &completedAt, &i.Recurrence, &dueAt, &i.Status, &i.Project, &i.Estimate, &deletedAt, &i.Blocked)
 		if err != nil {
 			return nil, err
 		}
+		i.CreatedAt = i.CreatedAt.In(db.location)
 		if completedAt.Valid {
-			i.CompletedAt = completedAt.Time
+			i.CompletedAt = completedAt.Time.In(db.location)
+		}
+		if dueAt.Valid {
+			i.DueAt = dueAt.Time.In(db.location)
+		}
+		if deletedAt.Valid {
+			i.DeletedAt = deletedAt.Time.In(db.location)
 		}
 		todos = append(todos, i)
 	}
@@ -92,62 +450,103 @@ func (db *DB) scanTodos(query string, args ...interface{}) ([]item, error) {
 
 }
 
+// Small bits of state the app keeps between runs, like the last standup
+func (db *DB) GetMeta(key string) (string, bool, error) {
+	var value string
+	err := db.QueryRow(`SELECT value FROM meta WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	return value, err == nil, err
+}
+
+func (db *DB) SetMeta(key, value string) error {
+	_, err := db.Exec(`
+		INSERT INTO meta (key, value) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value
+		`, key, value)
+	return err
+}
+
+var ErrNotFound = errors.New("todo not found")
+
+func (db *DB) GetTodo(id int) (item, error) {
+	todos, err := db.scanTodos(`
+		SELECT`+todoColumns+`
+		FROM
+				todos
+		WHERE
+				id = ?
+				AND deleted_at IS NULL;
+		`, id)
+	if err != nil {
+		return item{}, err
+	}
+	if len(todos) == 0 {
+		return item{}, fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	return todos[0], nil
+}
+
 func (db *DB) GetAllTodos() ([]item, error) {
 	return db.scanTodos(`
-		SELECT
-				id,
-				task,
-				done,
-				created_at,
-				completed_at
+		SELECT` + todoColumns + `
 		FROM
-				todos;
+				todos
+		WHERE
+				deleted_at IS NULL;
 		`)
 }
 
 func (db *DB) GetCompletedTodos(since time.Time) ([]item, error) {
 	return db.scanTodos(`
-		SELECT 
-				id, 
-				task,
-				done,
-				created_at,
-				completed_at
+		SELECT`+todoColumns+`
 		FROM 
 				todos
 		WHERE
 				done = 1
-				AND completed_at > ?;
-		`, since)
+				AND completed_at > ?
+				AND deleted_at IS NULL;
+		`, since.UTC())
+}
+
+// Completed todos in (since, until], a zero until leaves the end open
+func (db *DB) GetCompletedTodosBetween(since, until time.Time) ([]item, error) {
+	if until.IsZero() {
+		return db.GetCompletedTodos(since)
+	}
+	return db.scanTodos(`
+		SELECT`+todoColumns+`
+		FROM
+				todos
+		WHERE
+				done = 1
+				AND completed_at > ?
+				AND completed_at <= ?
+				AND deleted_at IS NULL;
+		`, since.UTC(), until.UTC())
 }
 
 func (db *DB) GetPendingTodos() ([]item, error) {
 	return db.scanTodos(`
-		SELECT
-				id,
-				task,
-				done,
-				created_at,
-				completed_at
+		SELECT` + todoColumns + `
 		FROM
 				todos 
 		WHERE 
-				done = 0;
+				done = 0
+				AND status != 'cancelled'
+				AND deleted_at IS NULL;
 		`)
 }
 
 func (db *DB) GetRecentOrPendingTodos(since time.Time) ([]item, error) {
 	return db.scanTodos(`
-		SELECT 
-				id, 
-				task,
-				done,
-				created_at,
-				completed_at
+		SELECT`+todoColumns+`
 		FROM 
 				todos
 		WHERE
 				done = 1
-				AND completed_at > ?;
-		`, since)
+				AND completed_at > ?
+				AND deleted_at IS NULL;
+		`, since.UTC())
 }
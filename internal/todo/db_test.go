@@ -206,9 +206,9 @@ func TestDeleteTodo(t *testing.T) {
 		t.Fatalf("DeleteTodo failed: %v", err)
 	}
 
-	// Verify deletion
+	// Verify deletion, the row stays in the trash
 	var count int
-	query := "SELECT COUNT(*) FROM todos WHERE id = ?"
+	query := "SELECT COUNT(*) FROM todos WHERE id = ? AND deleted_at IS NULL"
 	// Query using the embedded *sql.DB
 	err = db.DB.QueryRow(query, id).Scan(&count)
 	if err != nil {
@@ -217,6 +217,9 @@ func TestDeleteTodo(t *testing.T) {
 	if count != 0 {
 		t.Errorf("Expected count of todo with id %d to be 0 after deletion, got %d", id, count)
 	}
+	if _, err := db.GetTodo(id); err == nil {
+		t.Errorf("Expected GetTodo to miss the deleted todo %d", id)
+	}
 
 	// Test deleting a non-existent ID (should not error)
 	nonExistentID := 99998
@@ -0,0 +1,87 @@
+package todo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var ErrDependencyCycle = errors.New("dependency would create a cycle")
+
+// AddDependency records that blockerID has to be completed before blockedID
+func (db *DB) AddDependency(blockerID, blockedID int) error {
+	if blockerID == blockedID {
+		return fmt.Errorf("todo %d cannot block itself: %w", blockerID, ErrDependencyCycle)
+	}
+
+	return db.tracked([]int{blockedID}, func(tx *sql.Tx) error {
+		for _, id := range []int{blockerID, blockedID} {
+			var exists bool
+			if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM todos WHERE id = ? AND deleted_at IS NULL)`, id).Scan(&exists); err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("%w: %d", ErrNotFound, id)
+			}
+		}
+
+		// Walk everything downstream of the blocked todo, if the blocker is in there
+		// adding this edge would close a loop
+		var cycle bool
+		err := tx.QueryRow(`
+			WITH RECURSIVE downstream(id) AS (
+					SELECT ?
+					UNION
+					SELECT d.blocked_id
+					FROM dependencies d
+					JOIN downstream ON d.blocker_id = downstream.id
+			)
+			SELECT EXISTS (SELECT 1 FROM downstream WHERE id = ?);
+			`, blockedID, blockerID).Scan(&cycle)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return fmt.Errorf("todo %d already depends on todo %d: %w", blockerID, blockedID, ErrDependencyCycle)
+		}
+
+		_, err = tx.Exec(`
+			INSERT OR IGNORE INTO dependencies
+			(blocker_id, blocked_id) VALUES (?, ?)
+			`, blockerID, blockedID)
+		return err
+	})
+}
+
+func (db *DB) RemoveDependency(blockerID, blockedID int) error {
+	return db.tracked([]int{blockedID}, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			DELETE FROM dependencies WHERE blocker_id = ? AND blocked_id = ?
+			`, blockerID, blockedID)
+		return err
+	})
+}
+
+// Todos waiting on the given one, blocked or not
+func (db *DB) GetDependents(id int) ([]item, error) {
+	return db.scanTodos(`
+		SELECT`+todoColumns+`
+		FROM
+				todos
+		WHERE
+				id IN (SELECT blocked_id FROM dependencies WHERE blocker_id = ?)
+				AND deleted_at IS NULL;
+		`, id)
+}
+
+// Todos the given one is waiting on, completed or not
+func (db *DB) GetBlockers(id int) ([]item, error) {
+	return db.scanTodos(`
+		SELECT`+todoColumns+`
+		FROM
+				todos
+		WHERE
+				id IN (SELECT blocker_id FROM dependencies WHERE blocked_id = ?)
+				AND deleted_at IS NULL;
+		`, id)
+}
@@ -0,0 +1,168 @@
+package todo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAddDependency(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	idA := addTestTask(t, db, "Write migration")
+	idB := addTestTask(t, db, "Deploy")
+	idC := addTestTask(t, db, "Announce release")
+
+	// A blocks B, B blocks C
+	if err := db.AddDependency(idA, idB); err != nil {
+		t.Fatalf("AddDependency(%d, %d) failed: %v", idA, idB, err)
+	}
+	if err := db.AddDependency(idB, idC); err != nil {
+		t.Fatalf("AddDependency(%d, %d) failed: %v", idB, idC, err)
+	}
+
+	t.Run("Self dependency", func(t *testing.T) {
+		err := db.AddDependency(idA, idA)
+		if !errors.Is(err, ErrDependencyCycle) {
+			t.Errorf("Expected ErrDependencyCycle for a self dependency, got %v", err)
+		}
+	})
+
+	t.Run("Direct cycle", func(t *testing.T) {
+		err := db.AddDependency(idB, idA)
+		if !errors.Is(err, ErrDependencyCycle) {
+			t.Errorf("Expected ErrDependencyCycle for B -> A, got %v", err)
+		}
+	})
+
+	t.Run("Transitive cycle", func(t *testing.T) {
+		err := db.AddDependency(idC, idA)
+		if !errors.Is(err, ErrDependencyCycle) {
+			t.Errorf("Expected ErrDependencyCycle for C -> A, got %v", err)
+		}
+	})
+
+	t.Run("Duplicate edge is ignored", func(t *testing.T) {
+		if err := db.AddDependency(idA, idB); err != nil {
+			t.Errorf("Adding an existing dependency failed unexpectedly: %v", err)
+		}
+	})
+	t.Run("Missing or trashed todo", func(t *testing.T) {
+		if err := db.AddDependency(999, idA); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for an unknown blocker, got %v", err)
+		}
+		if err := db.AddDependency(idA, 999); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for an unknown blocked todo, got %v", err)
+		}
+
+		idD := addTestTask(t, db, "Trashed")
+		if err := db.DeleteTodo(idD); err != nil {
+			t.Fatalf("DeleteTodo failed: %v", err)
+		}
+		if err := db.AddDependency(idD, idC); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for a trashed blocker, got %v", err)
+		}
+
+		var orphans int
+		db.QueryRow(`SELECT COUNT(*) FROM dependencies WHERE blocker_id IN (999, ?) OR blocked_id = 999`, idD).Scan(&orphans)
+		if orphans != 0 {
+			t.Errorf("Expected no dependency rows for missing todos, got %d", orphans)
+		}
+	})
+}
+
+func TestBlockedState(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+
+	idBlocker := addTestTask(t, db, "Get approval")
+	idOther := addTestTask(t, db, "Book room")
+	idBlocked := addTestTask(t, db, "Run workshop")
+
+	if err := todos.Block(idBlocked, idBlocker); err != nil {
+		t.Fatalf("Block failed: %v", err)
+	}
+	if err := todos.Block(idBlocked, idOther); err != nil {
+		t.Fatalf("Block failed: %v", err)
+	}
+
+	isBlocked := func(id int) bool {
+		t.Helper()
+		pending, err := db.GetPendingTodos()
+		if err != nil {
+			t.Fatalf("GetPendingTodos failed: %v", err)
+		}
+		for _, item := range pending {
+			if item.ID == id {
+				return item.Blocked
+			}
+		}
+		t.Fatalf("Todo %d not found in pending todos", id)
+		return false
+	}
+
+	if !isBlocked(idBlocked) {
+		t.Errorf("Expected todo %d to be blocked", idBlocked)
+	}
+	if isBlocked(idBlocker) {
+		t.Errorf("Expected blocker %d not to be blocked", idBlocker)
+	}
+
+	tasks, _ := todos.GetTasks(time.Now(), false)
+	for _, task := range tasks {
+		if task == "Run workshop" {
+			t.Errorf("Blocked task was returned by GetTasks without includeBlocked")
+		}
+	}
+	tasks, _ = todos.GetTasks(time.Now(), true)
+	if len(tasks) != 3 {
+		t.Errorf("Expected 3 tasks with includeBlocked, got %d", len(tasks))
+	}
+
+	// Completing one of two blockers should not unblock anything yet
+	unblocked, err := todos.Complete(idBlocker)
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if len(unblocked) != 0 {
+		t.Errorf("Expected nothing unblocked after the first blocker, got %d", len(unblocked))
+	}
+
+	unblocked, err = todos.Complete(idOther)
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if len(unblocked) != 1 || unblocked[0].ID != idBlocked {
+		t.Fatalf("Expected todo %d to be unblocked, got %v", idBlocked, unblocked)
+	}
+	if isBlocked(idBlocked) {
+		t.Errorf("Expected todo %d not to be blocked once its blockers are done", idBlocked)
+	}
+
+	// Done already, so completing it again or cancelling it frees nothing new
+	unblocked, err = todos.Complete(idOther)
+	if err != nil || len(unblocked) != 0 {
+		t.Errorf("Expected nothing unblocked completing a done blocker again, got %v (err: %v)", unblocked, err)
+	}
+	unblocked, err = todos.Move(idBlocker, StatusCancelled)
+	if err != nil || len(unblocked) != 0 {
+		t.Errorf("Expected nothing unblocked cancelling a done blocker, got %v (err: %v)", unblocked, err)
+	}
+
+	// Purging a todo drops its dependency rows
+	if err := db.DeleteTodo(idBlocked); err != nil {
+		t.Fatalf("DeleteTodo failed: %v", err)
+	}
+	if _, err := db.PurgeTodos(time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("PurgeTodos failed: %v", err)
+	}
+	var count int
+	if err := db.DB.QueryRow("SELECT COUNT(*) FROM dependencies").Scan(&count); err != nil {
+		t.Fatalf("Failed to count dependencies: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected dependencies to be removed with the todo, %d left", count)
+	}
+}
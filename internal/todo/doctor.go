@@ -0,0 +1,302 @@
+package todo
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Rows that break an assumption the rest of the code makes. find selects the
+// IDs of the offending todos, repair fixes all of them at once
+var consistencyChecks = []struct {
+	name   string
+	find   string
+	repair string
+}{
+	{
+		name:   "unknown status",
+		find:   `SELECT id FROM todos WHERE status NOT IN ('todo', 'in-progress', 'waiting', 'blocked', 'done', 'cancelled')`,
+		repair: `UPDATE todos SET status = CASE WHEN done = 1 THEN 'done' ELSE 'todo' END WHERE status NOT IN ('todo', 'in-progress', 'waiting', 'blocked', 'done', 'cancelled')`,
+	},
+	{
+		// The status is what the workflow commands go by, done follows it
+		name:   "done out of sync with the status",
+		find:   `SELECT id FROM todos WHERE done != (status = 'done')`,
+		repair: `UPDATE todos SET done = (status = 'done') WHERE done != (status = 'done')`,
+	},
+	{
+		// Completed when it last moved to done, or when it was added
+		name: "done without completed_at",
+		find: `SELECT id FROM todos WHERE done = 1 AND completed_at IS NULL`,
+		repair: `UPDATE todos SET completed_at = COALESCE(
+				(SELECT MAX(changed_at) FROM status_transitions s WHERE s.todo_id = todos.id AND s.to_status = 'done'),
+				created_at)
+			WHERE done = 1 AND completed_at IS NULL`,
+	},
+	{
+		name:   "completed_at on an unfinished todo",
+		find:   `SELECT id FROM todos WHERE done = 0 AND completed_at IS NOT NULL`,
+		repair: `UPDATE todos SET completed_at = NULL WHERE done = 0 AND completed_at IS NOT NULL`,
+	},
+	{
+		name:   "completed_at before created_at",
+		find:   `SELECT id FROM todos WHERE completed_at < created_at`,
+		repair: `UPDATE todos SET completed_at = created_at WHERE completed_at < created_at`,
+	},
+	{
+		// The live row wins, it is the one commands have been changing
+		name:   "both listed and archived",
+		find:   `SELECT id FROM archive WHERE id IN (SELECT id FROM todos)`,
+		repair: `DELETE FROM archive WHERE id IN (SELECT id FROM todos)`,
+	},
+}
+
+// Something doctor found. Repairable problems carry the statements fixing them
+// and the todos those change, for the history
+type problem struct {
+	Check  string
+	Detail string
+	repair func(tx *sql.Tx) error
+	ids    []int
+}
+
+// Everything doctor found. Integrity holds what PRAGMA integrity_check
+// reported besides ok, which can't be repaired in place
+type diagnosis struct {
+	Integrity []string
+	Problems  []problem
+}
+
+func (d diagnosis) healthy() bool {
+	return len(d.Integrity) == 0 && len(d.Problems) == 0
+}
+
+// Runs SQLite's integrity and foreign key checks and the consistency checks
+func (db *DB) Diagnose() (diagnosis, error) {
+	var d diagnosis
+
+	rows, err := db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return d, err
+	}
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			rows.Close()
+			return d, err
+		}
+		if msg != "ok" {
+			d.Integrity = append(d.Integrity, msg)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return d, err
+	}
+
+	orphans, err := db.orphans()
+	if err != nil {
+		return d, err
+	}
+	d.Problems = append(d.Problems, orphans...)
+
+	for _, check := range consistencyChecks {
+		ids, err := db.ids(check.find)
+		if err != nil {
+			return d, fmt.Errorf("%s: %w", check.name, err)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		repair := check.repair
+		d.Problems = append(d.Problems, problem{
+			Check:  check.name,
+			Detail: "todos " + joinIDs(ids),
+			repair: func(tx *sql.Tx) error {
+				_, err := tx.Exec(repair)
+				return err
+			},
+			ids: ids,
+		})
+	}
+	return d, nil
+}
+
+// Rows PRAGMA foreign_key_check flags, minus references to archived todos
+// which are expected. The repair drops the row
+func (db *DB) orphans() ([]problem, error) {
+	rows, err := db.Query(`PRAGMA foreign_key_check`)
+	if err != nil {
+		return nil, err
+	}
+	type violation struct {
+		table string
+		rowid int64
+		fkid  int
+	}
+	var violations []violation
+	for rows.Next() {
+		var v violation
+		var parent string
+		var rowid sql.NullInt64
+		if err := rows.Scan(&v.table, &rowid, &parent, &v.fkid); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		v.rowid = rowid.Int64
+		violations = append(violations, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var problems []problem
+	for _, v := range violations {
+		column, err := db.foreignKeyColumn(v.table, v.fkid)
+		if err != nil {
+			return nil, err
+		}
+		// Table and column names come from SQLite itself
+		var ref int
+		var archived bool
+		err = db.QueryRow(fmt.Sprintf(`
+			SELECT %[1]s, EXISTS (SELECT 1 FROM archive WHERE id = %[1]s) FROM %[2]s WHERE rowid = ?
+			`, column, v.table), v.rowid).Scan(&ref, &archived)
+		if err != nil {
+			return nil, err
+		}
+		if archived {
+			continue
+		}
+
+		table, rowid := v.table, v.rowid
+		problems = append(problems, problem{
+			Check:  "orphaned reference",
+			Detail: fmt.Sprintf("%s row %d: %s %d does not exist", table, rowid, column, ref),
+			repair: func(tx *sql.Tx) error {
+				_, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE rowid = ?`, table), rowid)
+				return err
+			},
+		})
+	}
+	return problems, nil
+}
+
+// Column of table holding the foreign key with the given id
+func (db *DB) foreignKeyColumn(table string, fkid int) (string, error) {
+	rows, err := db.Query(fmt.Sprintf(`PRAGMA foreign_key_list(%s)`, table))
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, seq int
+		var parent, from, onUpdate, onDelete, match string
+		// to is NULL for keys referencing the primary key implicitly
+		var to sql.NullString
+		if err := rows.Scan(&id, &seq, &parent, &from, &to, &onUpdate, &onDelete, &match); err != nil {
+			return "", err
+		}
+		if id == fkid {
+			return from, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no foreign key %d on %s", fkid, table)
+}
+
+// Fixes every repairable problem in one transaction, tracked so the history
+// shows what changed. Returns how many were repaired, backing up first is up
+// to the caller
+func (db *DB) Repair(d diagnosis) (int, error) {
+	if len(d.Problems) == 0 {
+		return 0, nil
+	}
+
+	var ids []int
+	for _, p := range d.Problems {
+		ids = append(ids, p.ids...)
+	}
+	err := db.tracked(ids, func(tx *sql.Tx) error {
+		for _, p := range d.Problems {
+			if err := p.repair(tx); err != nil {
+				return fmt.Errorf("repairing %s: %w", p.Check, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(d.Problems), nil
+}
+
+func joinIDs(ids []int) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.Itoa(id)
+	}
+	return strings.Join(s, ", ")
+}
+
+// Checks the database and prints what it finds, fixing it when repair is
+// set. Returns how many problems are left
+func (t *Todos) Doctor(repair bool, out io.Writer) (int, error) {
+	d, err := t.db.Diagnose()
+	if err != nil {
+		return 0, err
+	}
+	if d.healthy() {
+		fmt.Fprintln(out, green("No problems found."))
+		return 0, nil
+	}
+
+	for _, msg := range d.Integrity {
+		fmt.Fprintf(out, "%s %s\n", red("integrity:"), msg)
+	}
+	for _, p := range d.Problems {
+		fmt.Fprintf(out, "%s %s\n", red(p.Check+":"), p.Detail)
+	}
+	if len(d.Integrity) > 0 {
+		fmt.Fprintln(out, "The database file is damaged, `todo backup ls` lists backups to restore from.")
+	}
+
+	if !repair {
+		if len(d.Problems) > 0 {
+			fmt.Fprintln(out, "Run todo doctor --repair to fix what can be fixed.")
+		}
+		return len(d.Integrity) + len(d.Problems), nil
+	}
+
+	if len(d.Problems) > 0 {
+		if _, err := t.db.Backup("repair"); err != nil {
+			return len(d.Integrity) + len(d.Problems), err
+		}
+	}
+
+	// One fix can bring up another, like done set from the status still
+	// missing its completed_at, so keep going while there is something to do.
+	// The backup above covers every pass
+	repaired := 0
+	for pass := 0; pass <= len(consistencyChecks) && len(d.Problems) > 0; pass++ {
+		n, err := t.db.Repair(d)
+		if err != nil {
+			return len(d.Integrity) + len(d.Problems), err
+		}
+		repaired += n
+		if d, err = t.db.Diagnose(); err != nil {
+			return 0, err
+		}
+	}
+	fmt.Fprintf(out, "Repaired %d problems, the database was backed up first.\n", repaired)
+	for _, p := range d.Problems {
+		fmt.Fprintf(out, "%s %s\n", red("still "+p.Check+":"), p.Detail)
+	}
+	return len(d.Integrity) + len(d.Problems), nil
+}
@@ -0,0 +1,126 @@
+package todo
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDoctor(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+
+	var out bytes.Buffer
+	if left, err := todos.Doctor(false, &out); err != nil || left != 0 {
+		t.Fatalf("Expected a fresh database to be healthy, got %d (err: %v)", left, err)
+	}
+
+	synced := addTestTask(t, db, "Status says done")
+	backwards := addTestTask(t, db, "Done before it was added")
+	stray := addTestTask(t, db, "Completed but pending")
+	archived := addTestTask(t, db, "Archived with a session")
+	completeAt(t, db, archived, time.Now().AddDate(0, -1, 0))
+	if err := db.StartTimer(archived); err != nil {
+		t.Fatalf("StartTimer failed: %v", err)
+	}
+	db.StopTimer()
+	if _, err := todos.Archive(0); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+
+	for _, stmt := range []string{
+		`UPDATE todos SET status = 'done' WHERE id = ` + strconv.Itoa(synced),
+		`UPDATE todos SET done = 1, status = 'done', completed_at = '2000-01-01 00:00:00+00:00' WHERE id = ` + strconv.Itoa(backwards),
+		`UPDATE todos SET completed_at = created_at WHERE id = ` + strconv.Itoa(stray),
+		`INSERT INTO dependencies (blocker_id, blocked_id) VALUES (404, ` + strconv.Itoa(stray) + `)`,
+		`INSERT INTO status_transitions (todo_id, from_status, to_status, changed_at) VALUES (405, 'todo', 'done', '2024-01-01 00:00:00+00:00')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	d, err := db.Diagnose()
+	if err != nil {
+		t.Fatalf("Diagnose failed: %v", err)
+	}
+	var found []string
+	for _, p := range d.Problems {
+		found = append(found, p.Check+": "+p.Detail)
+	}
+	want := []string{
+		"orphaned reference: dependencies row 1: blocker_id 404 does not exist",
+		"orphaned reference: status_transitions row 1: todo_id 405 does not exist",
+		"done out of sync with the status: todos " + strconv.Itoa(synced),
+		"completed_at on an unfinished todo: todos " + strconv.Itoa(stray),
+		"completed_at before created_at: todos " + strconv.Itoa(backwards),
+	}
+	if strings.Join(found, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Expected the archived todo's session left alone and\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(found, "\n"))
+	}
+
+	out.Reset()
+	left, err := todos.Doctor(false, &out)
+	if err != nil || left != len(want) {
+		t.Errorf("Expected %d problems reported, got %d (err: %v)", len(want), left, err)
+	}
+	if !strings.Contains(out.String(), "--repair") {
+		t.Errorf("Expected a hint to repair, got %q", out.String())
+	}
+
+	out.Reset()
+	if left, err := todos.Doctor(true, &out); err != nil || left != 0 {
+		t.Fatalf("Expected everything repaired, got %d left (err: %v)\n%s", left, err, out.String())
+	}
+
+	item, _ := db.GetTodo(synced)
+	if !item.Done || item.CompletedAt.IsZero() {
+		t.Errorf("Expected todo %d done with a completion time, got %+v", synced, item)
+	}
+	item, _ = db.GetTodo(backwards)
+	if !item.CompletedAt.Equal(item.CreatedAt) {
+		t.Errorf("Expected completed_at moved up to created_at, got %v and %v", item.CompletedAt, item.CreatedAt)
+	}
+	item, _ = db.GetTodo(stray)
+	if !item.CompletedAt.IsZero() {
+		t.Errorf("Expected the stray completed_at cleared, got %v", item.CompletedAt)
+	}
+	var orphans int
+	db.QueryRow(`SELECT (SELECT COUNT(*) FROM dependencies) + (SELECT COUNT(*) FROM status_transitions WHERE todo_id = 405)`).Scan(&orphans)
+	if orphans != 0 {
+		t.Errorf("Expected the orphaned rows dropped, %d left", orphans)
+	}
+	// The history shows what was repaired
+	repaired := func(id int, field, new string) {
+		t.Helper()
+		changes, err := db.GetHistory(id)
+		if err != nil {
+			t.Fatalf("GetHistory failed: %v", err)
+		}
+		for _, c := range changes {
+			if c.Field == field && c.New == new {
+				return
+			}
+		}
+		t.Errorf("Expected %s set to %q in the history of %d, got %+v", field, new, id, changes)
+	}
+	repaired(synced, "done", "true")
+	repaired(stray, "completed_at", "")
+	item, _ = db.GetTodo(backwards)
+	repaired(backwards, "completed_at", item.CreatedAt.UTC().Format(time.RFC3339))
+
+	// Several passes are needed here, they share the one backup
+	backups, _ := db.Backups()
+	repairs := 0
+	for _, b := range backups {
+		if b.Reason == "repair" {
+			repairs++
+		}
+	}
+	if repairs != 1 || backups[0].Reason != "repair" {
+		t.Errorf("Expected a single backup before repairing, got %+v", backups)
+	}
+}
@@ -0,0 +1,248 @@
+package todo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexeyco/simpletable"
+)
+
+// How big a todo is expected to be, either in story points or as a duration
+type Estimate struct {
+	Points   float64
+	Duration time.Duration
+}
+
+// Parses an estimate: a plain number or one ending in pt/pts is points,
+// anything else a duration like 90m or 2h30m. Empty, "-" and "none" clear it
+func ParseEstimate(s string) (Estimate, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || s == "-" || s == "none" {
+		return Estimate{}, nil
+	}
+
+	points := s
+	for _, suffix := range []string{"pts", "pt"} {
+		if strings.HasSuffix(points, suffix) {
+			points = strings.TrimSuffix(points, suffix)
+			break
+		}
+	}
+	if p, err := strconv.ParseFloat(strings.TrimSpace(points), 64); err == nil {
+		// ParseFloat takes inf and nan too, they would break every sum
+		if p <= 0 || math.IsInf(p, 0) || math.IsNaN(p) {
+			return Estimate{}, fmt.Errorf("estimate %q needs to be a positive number of points", s)
+		}
+		return Estimate{Points: p}, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return Estimate{}, fmt.Errorf("invalid estimate %q, expected points like 3 or 3pt, or a duration like 90m or 2h30m", s)
+	}
+	return Estimate{Duration: d}, nil
+}
+
+// Stored form of the estimate, empty when there is none
+func (e Estimate) String() string {
+	switch {
+	case e.Points > 0:
+		return strconv.FormatFloat(e.Points, 'g', -1, 64) + "pt"
+	case e.Duration > 0:
+		// 2h0m0s reads better as 2h
+		s := e.Duration.String()
+		if strings.HasSuffix(s, "m0s") {
+			s = strings.TrimSuffix(s, "0s")
+		}
+		if strings.HasSuffix(s, "h0m") {
+			s = strings.TrimSuffix(s, "0m")
+		}
+		return s
+	}
+	return ""
+}
+
+func (e Estimate) IsZero() bool {
+	return e.Points == 0 && e.Duration == 0
+}
+
+// Estimate of a todo, todos with an unreadable one count as not estimated
+func (i item) estimate() Estimate {
+	e, _ := ParseEstimate(i.Estimate)
+	return e
+}
+
+// Adds up points and durations separately, there is no exchange rate
+func sumEstimates(todos []item) (float64, time.Duration) {
+	var points float64
+	var d time.Duration
+	for _, item := range todos {
+		e := item.estimate()
+		points += e.Points
+		d += e.Duration
+	}
+	return points, d
+}
+
+// Like "8pt, 5h 30m", empty without any estimate
+func formatEstimates(points float64, d time.Duration) string {
+	var parts []string
+	if points > 0 {
+		parts = append(parts, Estimate{Points: points}.String())
+	}
+	if d > 0 {
+		parts = append(parts, formatDuration(d))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Estimates of the completed todos in one project next to what they took
+type estimateRow struct {
+	Project string
+
+	// Todos estimated as a duration and the time tracked on them
+	Timed     int
+	Estimated time.Duration
+	Tracked   time.Duration
+
+	// Todos estimated in points, with their tracked and lead time
+	Pointed       int
+	Points        float64
+	PointsTracked time.Duration
+	PointsLead    time.Duration
+}
+
+// Compares estimates with the tracked time and lead time of the todos
+// completed since since, per project. A non empty project limits it to that one
+func (t *Todos) EstimateReport(project string, since time.Time) ([]estimateRow, error) {
+	completed, err := t.completedBetween(since, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	spent, err := t.db.TimeSpent(time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := map[string]*estimateRow{}
+	for _, item := range completed {
+		e := item.estimate()
+		if e.IsZero() || (project != "" && item.Project != project) {
+			continue
+		}
+		name := item.Project
+		if name == "" {
+			name = noProject
+		}
+		row, ok := rows[name]
+		if !ok {
+			row = &estimateRow{Project: name}
+			rows[name] = row
+		}
+
+		if e.Duration > 0 {
+			row.Timed++
+			row.Estimated += e.Duration
+			row.Tracked += spent[item.ID]
+			continue
+		}
+		row.Pointed++
+		row.Points += e.Points
+		row.PointsTracked += spent[item.ID]
+		row.PointsLead += item.CompletedAt.Sub(item.CreatedAt)
+	}
+
+	var report []estimateRow
+	for _, row := range rows {
+		report = append(report, *row)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Project < report[j].Project })
+	return report, nil
+}
+
+// Prints the estimate report as two tables, one for duration estimates and
+// one for points
+func (t *Todos) PrintEstimates(project string, since time.Time) error {
+	rows, err := t.EstimateReport(project, since)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		fmt.Println("No completed todos with an estimate.")
+		return nil
+	}
+
+	timed := simpletable.New()
+	timed.Header = &simpletable.Header{
+		Cells: []*simpletable.Cell{
+			{Align: simpletable.AlignCenter, Text: "Project"},
+			{Align: simpletable.AlignCenter, Text: "Todos"},
+			{Align: simpletable.AlignCenter, Text: "Estimated"},
+			{Align: simpletable.AlignCenter, Text: "Tracked"},
+			{Align: simpletable.AlignCenter, Text: "Actual/Est"},
+		},
+	}
+	pointed := simpletable.New()
+	pointed.Header = &simpletable.Header{
+		Cells: []*simpletable.Cell{
+			{Align: simpletable.AlignCenter, Text: "Project"},
+			{Align: simpletable.AlignCenter, Text: "Todos"},
+			{Align: simpletable.AlignCenter, Text: "Points"},
+			{Align: simpletable.AlignCenter, Text: "Tracked/pt"},
+			{Align: simpletable.AlignCenter, Text: "Lead time/pt"},
+		},
+	}
+
+	for _, row := range rows {
+		if row.Timed > 0 {
+			ratio := "-"
+			if row.Tracked > 0 {
+				ratio = ratioStyle(float64(row.Tracked) / float64(row.Estimated))
+			}
+			timed.Body.Cells = append(timed.Body.Cells, []*simpletable.Cell{
+				{Text: row.Project},
+				{Align: simpletable.AlignRight, Text: strconv.Itoa(row.Timed)},
+				{Align: simpletable.AlignRight, Text: formatDuration(row.Estimated)},
+				{Align: simpletable.AlignRight, Text: formatSpent(row.Tracked)},
+				{Align: simpletable.AlignRight, Text: ratio},
+			})
+		}
+		if row.Pointed > 0 && row.Points > 0 {
+			perPoint := func(d time.Duration) string {
+				return formatSpent(time.Duration(float64(d) / row.Points))
+			}
+			pointed.Body.Cells = append(pointed.Body.Cells, []*simpletable.Cell{
+				{Text: row.Project},
+				{Align: simpletable.AlignRight, Text: strconv.Itoa(row.Pointed)},
+				{Align: simpletable.AlignRight, Text: strconv.FormatFloat(row.Points, 'g', -1, 64)},
+				{Align: simpletable.AlignRight, Text: perPoint(row.PointsTracked)},
+				{Align: simpletable.AlignRight, Text: perPoint(row.PointsLead)},
+			})
+		}
+	}
+
+	for _, table := range []*simpletable.Table{timed, pointed} {
+		if len(table.Body.Cells) == 0 {
+			continue
+		}
+		table.SetStyle(simpletable.StyleUnicode)
+		table.Println()
+	}
+	return nil
+}
+
+// Colors how far off the actual time was: within 20% is green, over red
+func ratioStyle(r float64) string {
+	s := fmt.Sprintf("%.2fx", r)
+	switch {
+	case r > 1.2:
+		return red(s)
+	case r >= 0.8:
+		return green(s)
+	}
+	return blue(s)
+}
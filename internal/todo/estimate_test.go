@@ -0,0 +1,100 @@
+package todo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseEstimate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Estimate
+		str  string
+	}{
+		{"3", Estimate{Points: 3}, "3pt"},
+		{"0.5pt", Estimate{Points: 0.5}, "0.5pt"},
+		{"8 pts", Estimate{Points: 8}, "8pt"},
+		{"90m", Estimate{Duration: 90 * time.Minute}, "1h30m"},
+		{"2h", Estimate{Duration: 2 * time.Hour}, "2h"},
+		{"10m", Estimate{Duration: 10 * time.Minute}, "10m"},
+		{"none", Estimate{}, ""},
+		{"", Estimate{}, ""},
+	}
+	for _, tt := range tests {
+		got, err := ParseEstimate(tt.in)
+		if err != nil {
+			t.Errorf("ParseEstimate(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want || got.String() != tt.str {
+			t.Errorf("ParseEstimate(%q) = %+v (%q), want %+v (%q)", tt.in, got, got.String(), tt.want, tt.str)
+		}
+	}
+
+	for _, bad := range []string{"5x", "-2", "-1h", "soon", "0", "0pt", "0s", "inf", "+Inf", "-inf", "nan", "NaNpt", "1e400", "1e400pt"} {
+		if _, err := ParseEstimate(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestEstimateReport(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+
+	add := func(task, project, estimate string) int {
+		t.Helper()
+		if err := todos.AddWithOptions(task, AddOptions{Project: project, Estimate: estimate}); err != nil {
+			t.Fatalf("AddWithOptions failed: %v", err)
+		}
+		var id int
+		db.QueryRow(`SELECT MAX(id) FROM todos`).Scan(&id)
+		return id
+	}
+	track := func(id int, d time.Duration) {
+		t.Helper()
+		start := time.Now().Add(-d)
+		_, err := db.Exec(`INSERT INTO sessions (todo_id, started_at, ended_at) VALUES (?, ?, ?)`, id, start.UTC(), start.Add(d).UTC())
+		if err != nil {
+			t.Fatalf("Inserting session failed: %v", err)
+		}
+	}
+
+	timed := add("Timed", "web", "2h")
+	track(timed, 3*time.Hour)
+	pointed := add("Pointed", "web", "4pt")
+	track(pointed, 2*time.Hour)
+	add("Still open", "web", "1h")
+	add("Not estimated", "web", "")
+	other := add("Other", "", "30m")
+	for _, id := range []int{timed, pointed, other, 4} {
+		if _, err := todos.Complete(id); err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+	}
+
+	rows, err := todos.EstimateReport("", time.Time{})
+	if err != nil {
+		t.Fatalf("EstimateReport failed: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Project != noProject || rows[1].Project != "web" {
+		t.Fatalf("Expected a row per project, got %+v", rows)
+	}
+	web := rows[1]
+	if web.Timed != 1 || web.Estimated != 2*time.Hour || web.Tracked != 3*time.Hour {
+		t.Errorf("Unexpected duration estimates: %+v", web)
+	}
+	if web.Pointed != 1 || web.Points != 4 || web.PointsTracked != 2*time.Hour {
+		t.Errorf("Unexpected point estimates: %+v", web)
+	}
+
+	rows, _ = todos.EstimateReport("web", time.Time{})
+	if len(rows) != 1 {
+		t.Errorf("Expected only the web project, got %+v", rows)
+	}
+
+	if got := todos.pendingSummary(); got != "you have 1 pending todos (1h 0m estimated)" {
+		t.Errorf("Unexpected footer %q", got)
+	}
+}
@@ -0,0 +1,264 @@
+package todo
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	chartHeight = 12
+	// Characters per day in the charts
+	chartColumn = 2
+)
+
+// How many todos were in each state at the end of a day
+type flowPoint struct {
+	Day    time.Time
+	Counts map[string]int
+}
+
+// Todos that were neither done nor cancelled
+func (p flowPoint) open() int {
+	return p.Counts[StatusTodo] + p.Counts[StatusInProgress] + p.Counts[StatusWaiting] + p.Counts[StatusBlocked]
+}
+
+// Replays the recorded transitions to find the state of every todo (in
+// project, or all of them when empty) at the end of each day in [since, until)
+func (t *Todos) Flow(project string, since, until time.Time) ([]flowPoint, error) {
+	todos, err := t.allTodos()
+	if err != nil {
+		return nil, err
+	}
+	transitions, err := t.db.GetAllTransitions()
+	if err != nil {
+		return nil, err
+	}
+
+	byTodo := map[int][]transition{}
+	for _, tr := range transitions {
+		byTodo[tr.TodoID] = append(byTodo[tr.TodoID], tr)
+	}
+
+	var points []flowPoint
+	for day := startOfDay(since.In(t.db.location)); day.Before(until); day = day.AddDate(0, 0, 1) {
+		end := day.AddDate(0, 0, 1)
+		point := flowPoint{Day: day, Counts: map[string]int{}}
+		for _, item := range todos {
+			if project != "" && item.Project != project {
+				continue
+			}
+			if status, ok := statusAt(item, byTodo[item.ID], end); ok {
+				point.Counts[status]++
+			}
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// The state a todo was in at time at, false when it did not exist yet
+func statusAt(i item, transitions []transition, at time.Time) (string, bool) {
+	if i.CreatedAt.After(at) {
+		return "", false
+	}
+	if len(transitions) == 0 {
+		// Nothing recorded, all we know is where it is now
+		if i.Done && i.CompletedAt.After(at) {
+			return StatusTodo, true
+		}
+		return i.Status, true
+	}
+
+	status := transitions[0].From
+	for _, tr := range transitions {
+		if tr.ChangedAt.After(at) {
+			break
+		}
+		status = tr.To
+	}
+	return status, true
+}
+
+// Open todos per day as columns, with a dotted line for the ideal burndown
+// from the first day anything was open down to zero on the last day
+func renderBurndown(points []flowPoint) string {
+	series := make([][]int, len(points))
+	ideal := make([]float64, len(points))
+	first := -1
+	for n, p := range points {
+		series[n] = []int{p.open()}
+		if first < 0 && p.open() > 0 {
+			first = n
+		}
+	}
+	if first >= 0 {
+		start := float64(points[first].open())
+		for n := first; n < len(points); n++ {
+			ideal[n] = start
+			if last := len(points) - 1; last > first {
+				ideal[n] = start * float64(last-n) / float64(last-first)
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(renderColumns(points, series, []func(string) string{blue}, ideal))
+	b.WriteString("    " + blue("█") + " open  " + gray("·") + " ideal\n")
+	return b.String()
+}
+
+// States stacked bottom to top in the cumulative flow chart. Cancelled todos
+// left the flow and are not shown
+var flowStates = []string{StatusDone, StatusBlocked, StatusWaiting, StatusInProgress, StatusTodo}
+
+func flowStyle(status string) func(string) string {
+	switch status {
+	case StatusDone:
+		return green
+	case StatusInProgress:
+		return blue
+	case StatusBlocked:
+		return red
+	case StatusWaiting:
+		return gray
+	}
+	return func(s string) string { return s }
+}
+
+func renderFlow(points []flowPoint) string {
+	series := make([][]int, len(points))
+	for n, p := range points {
+		for _, status := range flowStates {
+			series[n] = append(series[n], p.Counts[status])
+		}
+	}
+	var styles []func(string) string
+	for _, status := range flowStates {
+		styles = append(styles, flowStyle(status))
+	}
+
+	var b strings.Builder
+	b.WriteString(renderColumns(points, series, styles, nil))
+	b.WriteString("   ")
+	// Legend top to bottom, the same order the layers are seen in
+	for n := len(flowStates) - 1; n >= 0; n-- {
+		b.WriteString(" " + styles[n]("█") + " " + flowStates[n])
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// Draws one column per point, with the values of series stacked bottom up.
+// marks, when given, is drawn as dots in the empty space above the columns
+func renderColumns(points []flowPoint, series [][]int, styles []func(string) string, marks []float64) string {
+	most := 1
+	for _, values := range series {
+		most = max(most, sum(values))
+	}
+	label := len(strconv.Itoa(most))
+
+	var b strings.Builder
+	for row := chartHeight - 1; row >= 0; row-- {
+		switch row {
+		case chartHeight - 1:
+			fmt.Fprintf(&b, "%*d ┤", label, most)
+		case 0:
+			fmt.Fprintf(&b, "%*d ┤", label, 0)
+		default:
+			fmt.Fprintf(&b, "%*s │", label, "")
+		}
+
+		for n, values := range series {
+			cell := strings.Repeat(" ", chartColumn)
+			// Each layer fills the rows up to its scaled running total
+			total := 0
+			for k, v := range values {
+				total += v
+				if v > 0 && row < scaled(total, most) {
+					cell = styles[k](strings.Repeat("█", chartColumn))
+					break
+				}
+			}
+			if marks != nil && marks[n] > 0 && !strings.Contains(cell, "█") && row == scaled(int(marks[n]+0.5), most)-1 {
+				cell = gray("·") + " "
+			}
+			b.WriteString(cell)
+		}
+		b.WriteString("\n")
+	}
+
+	// Dates of the first and last column underneath
+	if len(points) > 0 {
+		first := points[0].Day.Format("Jan 02")
+		last := points[len(points)-1].Day.Format("Jan 02")
+		pad := strings.Repeat(" ", label+2)
+		gap := len(points)*chartColumn - len(first) - len(last)
+		if gap > 0 {
+			fmt.Fprintf(&b, "%s%s%s%s\n", pad, first, strings.Repeat(" ", gap), last)
+		} else {
+			fmt.Fprintf(&b, "%s%s - %s\n", pad, first, last)
+		}
+	}
+	return b.String()
+}
+
+// Rows a value takes up, anything above zero gets at least one
+func scaled(v, most int) int {
+	if v <= 0 {
+		return 0
+	}
+	return max(1, (v*chartHeight+most/2)/most)
+}
+
+// Writes the series as CSV, one row per day with a column per state
+func writeFlowCSV(w io.Writer, points []flowPoint) error {
+	out := csv.NewWriter(w)
+	header := append([]string{"date"}, Statuses...)
+	if err := out.Write(append(header, "open")); err != nil {
+		return err
+	}
+	for _, p := range points {
+		row := []string{p.Day.Format(dayFormat)}
+		for _, status := range Statuses {
+			row = append(row, strconv.Itoa(p.Counts[status]))
+		}
+		if err := out.Write(append(row, strconv.Itoa(p.open()))); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// Prints the burndown or cumulative flow chart, and the series as CSV to
+// csvOut when it is set
+func (t *Todos) PrintFlow(chart, project string, since, until time.Time, csvOut io.Writer) error {
+	title := "Burndown"
+	render := renderBurndown
+	switch chart {
+	case "burndown":
+	case "flow":
+		title = "Cumulative flow"
+		render = renderFlow
+	default:
+		return fmt.Errorf("unknown chart %q, expected burndown or flow", chart)
+	}
+	if project != "" {
+		title += " for " + project
+	}
+
+	points, err := t.Flow(project, since, until)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\n\n%s", title, render(points))
+
+	if csvOut == nil {
+		return nil
+	}
+	return writeFlowCSV(csvOut, points)
+}
@@ -0,0 +1,126 @@
+package todo
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusAt(t *testing.T) {
+	at := func(d int) time.Time {
+		return time.Date(2024, 9, d, 12, 0, 0, 0, time.UTC)
+	}
+	i := item{Status: StatusDone, Done: true, CreatedAt: at(2), CompletedAt: at(6)}
+	transitions := []transition{
+		{From: StatusTodo, To: StatusInProgress, ChangedAt: at(4)},
+		{From: StatusInProgress, To: StatusDone, ChangedAt: at(6)},
+	}
+
+	tests := []struct {
+		day    int
+		want   string
+		exists bool
+	}{
+		{1, "", false},
+		{3, StatusTodo, true},
+		{5, StatusInProgress, true},
+		{7, StatusDone, true},
+	}
+	for _, tt := range tests {
+		got, ok := statusAt(i, transitions, at(tt.day))
+		if got != tt.want || ok != tt.exists {
+			t.Errorf("statusAt(day %d) = %q, %v, want %q, %v", tt.day, got, ok, tt.want, tt.exists)
+		}
+	}
+
+	// Without history a done todo counts as open until it was completed
+	if got, _ := statusAt(i, nil, at(5)); got != StatusTodo {
+		t.Errorf("Expected todo before completion without transitions, got %q", got)
+	}
+}
+
+func TestFlow(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	db.SetLocation(time.UTC)
+	todos := NewTodos(db)
+
+	day := func(d, h int) time.Time {
+		return time.Date(2024, 9, d, h, 0, 0, 0, time.UTC)
+	}
+	add := func(task, project string, created time.Time) int {
+		t.Helper()
+		id := addTestTask(t, db, task)
+		_, err := db.Exec(`UPDATE todos SET created_at = ?, project = ? WHERE id = ?`, created.UTC(), project, id)
+		if err != nil {
+			t.Fatalf("Backdating failed: %v", err)
+		}
+		return id
+	}
+	move := func(id int, to string, at time.Time) {
+		t.Helper()
+		if err := db.SetStatus(id, to); err != nil {
+			t.Fatalf("SetStatus failed: %v", err)
+		}
+		_, err := db.Exec(`UPDATE status_transitions SET changed_at = ? WHERE todo_id = ? AND to_status = ?`, at.UTC(), id, to)
+		if err != nil {
+			t.Fatalf("Backdating transition failed: %v", err)
+		}
+	}
+
+	first := add("First", "sprint", day(16, 9))
+	second := add("Second", "sprint", day(16, 9))
+	add("Third", "sprint", day(17, 9))
+	add("Elsewhere", "other", day(16, 9))
+	move(first, StatusInProgress, day(16, 15))
+	move(first, StatusDone, day(18, 10))
+	move(second, StatusCancelled, day(17, 10))
+
+	points, err := todos.Flow("sprint", day(15, 0), day(19, 0))
+	if err != nil {
+		t.Fatalf("Flow failed: %v", err)
+	}
+	if len(points) != 4 {
+		t.Fatalf("Expected 4 days, got %d", len(points))
+	}
+
+	wantOpen := []int{0, 2, 2, 1}
+	for n, p := range points {
+		if p.open() != wantOpen[n] {
+			t.Errorf("Day %s: expected %d open, got %d (%v)", p.Day.Format(dayFormat), wantOpen[n], p.open(), p.Counts)
+		}
+	}
+	if points[1].Counts[StatusInProgress] != 1 || points[3].Counts[StatusDone] != 1 || points[2].Counts[StatusCancelled] != 1 {
+		t.Errorf("Unexpected counts per state: %v", points)
+	}
+
+	var csv strings.Builder
+	if err := writeFlowCSV(&csv, points); err != nil {
+		t.Fatalf("writeFlowCSV failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if lines[0] != "date,todo,in-progress,waiting,blocked,done,cancelled,open" {
+		t.Errorf("Unexpected CSV header %q", lines[0])
+	}
+	if lines[4] != "2024-09-18,1,0,0,0,1,1,1" {
+		t.Errorf("Unexpected CSV row %q", lines[4])
+	}
+}
+
+func TestRenderColumns(t *testing.T) {
+	points := []flowPoint{{Day: time.Date(2024, 9, 16, 0, 0, 0, 0, time.UTC)}, {Day: time.Date(2024, 9, 17, 0, 0, 0, 0, time.UTC)}}
+	plain := func(s string) string { return s }
+	out := renderColumns(points, [][]int{{4}, {2}}, []func(string) string{plain}, nil)
+	lines := strings.Split(out, "\n")
+
+	if len(lines) < chartHeight+1 {
+		t.Fatalf("Expected %d rows and the dates, got\n%s", chartHeight, out)
+	}
+	// The top row only has the taller column, the bottom one both
+	if !strings.HasSuffix(lines[0], "██  ") || !strings.HasSuffix(lines[chartHeight-1], "████") {
+		t.Errorf("Unexpected columns\n%s", out)
+	}
+	if !strings.HasPrefix(lines[0], "4 ┤") || !strings.HasPrefix(lines[chartHeight-1], "0 ┤") {
+		t.Errorf("Expected the scale on the axis\n%s", out)
+	}
+}
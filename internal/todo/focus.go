@@ -0,0 +1,100 @@
+package todo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+const bell = "\a"
+
+// Lengths of a focus run, zero values come from the config
+type FocusOptions struct {
+	Work   time.Duration
+	Break  time.Duration
+	Rounds int
+}
+
+// Runs pomodoro rounds on a todo: work with a live countdown, ring the bell,
+// take a break, repeat. Every work round is logged as a session on the todo.
+// Cancelling ctx (Ctrl-C) stops early and still logs the partial round.
+// Returns how many full rounds were worked
+func (t *Todos) Focus(ctx context.Context, id int, opts FocusOptions, out io.Writer) (int, error) {
+	if opts.Work == 0 {
+		opts.Work = time.Duration(t.config.FocusMinutes) * time.Minute
+	}
+	if opts.Break == 0 {
+		opts.Break = time.Duration(t.config.BreakMinutes) * time.Minute
+	}
+	if opts.Rounds < 1 {
+		opts.Rounds = 1
+	}
+
+	item, err := t.db.GetTodo(id)
+	if err != nil {
+		return 0, err
+	}
+
+	for round := 1; round <= opts.Rounds; round++ {
+		if err := t.start(id, sessionFocus); err != nil {
+			return round - 1, err
+		}
+		label := fmt.Sprintf("Focus on %s (%d/%d)", item.Task, round, opts.Rounds)
+		interrupted := countdown(ctx, opts.Work, label, out)
+
+		// The session is logged whether the round finished or not
+		s, err := t.db.StopTimer()
+		if err != nil {
+			return round - 1, err
+		}
+		if interrupted != nil {
+			fmt.Fprintf(out, "Stopped after %s, logged on %s\n", formatClock(s.EndedAt.Sub(s.StartedAt)), item.Task)
+			return round - 1, nil
+		}
+		fmt.Fprintf(out, "%sRound %d done, %s logged on %s\n", bell, round, formatDuration(opts.Work), item.Task)
+
+		if round == opts.Rounds || opts.Break == 0 {
+			continue
+		}
+		if countdown(ctx, opts.Break, "Break", out) != nil {
+			fmt.Fprintln(out, "Break cut short")
+			return round, nil
+		}
+		fmt.Fprintf(out, "%sBreak over\n", bell)
+	}
+	return opts.Rounds, nil
+}
+
+// Shows the time left on one line until d has passed, or ctx is done in
+// which case its error is returned
+func countdown(ctx context.Context, d time.Duration, label string, out io.Writer) error {
+	end := time.Now().Add(d)
+	done := time.NewTimer(d)
+	defer done.Stop()
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+
+	for {
+		// \r and clearing the line redraws the countdown in place
+		fmt.Fprintf(out, "\r\x1b[K%s %s", formatClock(time.Until(end)), label)
+		select {
+		case <-ctx.Done():
+			fmt.Fprint(out, "\r\x1b[K")
+			return ctx.Err()
+		case <-done.C:
+			fmt.Fprint(out, "\r\x1b[K")
+			return nil
+		case <-tick.C:
+		}
+	}
+}
+
+// Minutes and seconds left, like 24:59
+func formatClock(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < 0 {
+		d = 0
+	}
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
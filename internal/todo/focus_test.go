@@ -0,0 +1,74 @@
+package todo
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFocus(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+	id := addTestTask(t, db, "Deep work")
+
+	t.Run("Full rounds", func(t *testing.T) {
+		var out strings.Builder
+		rounds, err := todos.Focus(context.Background(), id, FocusOptions{Work: 50 * time.Millisecond, Break: 10 * time.Millisecond, Rounds: 2}, &out)
+		if err != nil || rounds != 2 {
+			t.Fatalf("Expected 2 rounds, got %d (err: %v)", rounds, err)
+		}
+		if n, _ := db.FocusCount(id); n != 2 {
+			t.Errorf("Expected 2 focus sessions, got %d", n)
+		}
+		if !strings.Contains(out.String(), bell) || !strings.Contains(out.String(), "Focus on Deep work (2/2)") {
+			t.Errorf("Expected the countdown and a bell, got %q", out.String())
+		}
+		if item, _ := db.GetTodo(id); item.Status != StatusInProgress {
+			t.Errorf("Expected the todo in progress, got %s", item.Status)
+		}
+	})
+
+	t.Run("Interrupted round is logged", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		rounds, err := todos.Focus(ctx, id, FocusOptions{Work: time.Minute}, io.Discard)
+		if err != nil || rounds != 0 {
+			t.Fatalf("Expected no full round, got %d (err: %v)", rounds, err)
+		}
+		if _, ok, _ := db.RunningTimer(); ok {
+			t.Error("Expected the timer stopped")
+		}
+
+		sessions, _ := db.GetSessions(time.Time{}, time.Time{})
+		last := sessions[len(sessions)-1]
+		if last.Kind != sessionFocus || last.EndedAt.Sub(last.StartedAt) >= time.Minute {
+			t.Errorf("Expected a partial focus session, got %+v", last)
+		}
+	})
+
+	t.Run("Timer already running", func(t *testing.T) {
+		if err := todos.Start(id); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		defer db.StopTimer()
+		if _, err := todos.Focus(context.Background(), id, FocusOptions{Work: time.Millisecond}, io.Discard); err == nil {
+			t.Error("Expected an error with a timer running")
+		}
+	})
+}
+
+func TestFormatClock(t *testing.T) {
+	tests := map[time.Duration]string{
+		25 * time.Minute:                      "25:00",
+		90*time.Second + 400*time.Millisecond: "01:30",
+		-time.Second:                          "00:00",
+	}
+	for d, want := range tests {
+		if got := formatClock(d); got != want {
+			t.Errorf("formatClock(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package todo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var ErrNoMatch = errors.New("no pending todo matches")
+
+// Scores how well pattern matches text as a case-insensitive subsequence.
+// Consecutive characters, word starts and plain substrings score higher
+func fuzzyScore(pattern, text string) (int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	t := []rune(strings.ToLower(text))
+	if len(p) == 0 {
+		return 0, true
+	}
+
+	score, pi, prev := 0, 0, -2
+	for ti, r := range t {
+		if pi == len(p) {
+			break
+		}
+		if r != p[pi] {
+			continue
+		}
+		score++
+		if ti == prev+1 {
+			score += 2
+		}
+		if ti == 0 || !(unicode.IsLetter(t[ti-1]) || unicode.IsDigit(t[ti-1])) {
+			score += 3
+		}
+		prev = ti
+		pi++
+	}
+	if pi < len(p) {
+		return 0, false
+	}
+
+	if strings.Contains(string(t), string(p)) {
+		score += 10 * len(p)
+	}
+	return score, true
+}
+
+// Pending todos matching the query, best match first. When some todos contain
+// the query as is, looser subsequence matches are left out
+func (t *Todos) Match(query string) ([]item, error) {
+	pending, err := t.db.GetPendingTodos()
+	if err != nil {
+		return nil, err
+	}
+
+	type scored struct {
+		item      item
+		score     int
+		substring bool
+	}
+	var matches []scored
+	anySubstring := false
+	for _, item := range pending {
+		score, ok := fuzzyScore(query, item.Task)
+		if !ok {
+			continue
+		}
+		substring := strings.Contains(strings.ToLower(item.Task), strings.ToLower(query))
+		anySubstring = anySubstring || substring
+		matches = append(matches, scored{item, score, substring})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	var todos []item
+	for _, m := range matches {
+		if m.substring || !anySubstring {
+			todos = append(todos, m.item)
+		}
+	}
+	return todos, nil
+}
+
+// Turns what the user typed in place of an ID into one. It can be the ID, the
+// UID or a prefix of it (like the handles shown in listings) or text matched
+// against pending todos. An empty reference or several matches bring up a picker on in/out
+func (t *Todos) Resolve(ref string, in io.Reader, out io.Writer) (int, error) {
+	ref = strings.TrimSpace(ref)
+	id, numErr := strconv.Atoi(ref)
+	if numErr == nil {
+		exists, err := t.db.todoExists(id)
+		if err != nil {
+			return 0, err
+		}
+		if exists {
+			return id, nil
+		}
+	}
+
+	// Handles always have a letter, digits alone are an ID even when no todo has it
+	if prefix := compactUID(ref); len(prefix) >= minHandleLength && isHex(prefix) && strings.ContainsAny(prefix, "abcdef") {
+		ids, err := t.db.FindByUIDPrefix(prefix)
+		if err != nil {
+			return 0, err
+		}
+		if len(ids) > 1 {
+			return 0, fmt.Errorf("handle %q matches %d todos, use a longer one", ref, len(ids))
+		}
+		if len(ids) == 1 {
+			return ids[0], nil
+		}
+	}
+
+	// Unknown IDs are passed on, the commands report them as not found
+	if numErr == nil {
+		return id, nil
+	}
+
+	matches, err := t.Match(ref)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case len(matches) == 0:
+		return 0, fmt.Errorf("%w %q", ErrNoMatch, ref)
+	case len(matches) == 1 && ref != "":
+		return matches[0].ID, nil
+	}
+
+	for _, item := range matches {
+		if strings.EqualFold(item.Task, ref) {
+			return item.ID, nil
+		}
+	}
+
+	picked, err := t.pick(matches, bufio.NewScanner(in), out)
+	if err != nil {
+		return 0, err
+	}
+	return picked.ID, nil
+}
+
+// Lists the candidates and asks for one. Typing text instead of a number
+// narrows the list down with the same fuzzy matching
+func (t *Todos) pick(candidates []item, scanner *bufio.Scanner, out io.Writer) (item, error) {
+	for {
+		for n, item := range candidates {
+			fmt.Fprintf(out, "%3d) %s %s\n", n+1, gray(fmt.Sprintf("#%d", item.ID)), item.Task)
+		}
+		fmt.Fprintf(out, "Pick a todo [1-%d] or type to narrow: ", len(candidates))
+
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return item{}, err
+			}
+			return item{}, errors.New("no todo picked")
+		}
+		answer := strings.TrimSpace(scanner.Text())
+
+		if n, err := strconv.Atoi(answer); err == nil {
+			if n >= 1 && n <= len(candidates) {
+				return candidates[n-1], nil
+			}
+			fmt.Fprintf(out, "%d is not in the list\n", n)
+			continue
+		}
+
+		var narrowed []item
+		for _, item := range candidates {
+			if _, ok := fuzzyScore(answer, item.Task); ok {
+				narrowed = append(narrowed, item)
+			}
+		}
+		switch len(narrowed) {
+		case 0:
+			fmt.Fprintf(out, "nothing matches %q\n", answer)
+		case 1:
+			return narrowed[0], nil
+		default:
+			candidates = narrowed
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package todo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	if _, ok := fuzzyScore("dply", "Deploy staging"); !ok {
+		t.Error("Expected 'dply' to match 'Deploy staging' as a subsequence")
+	}
+	if _, ok := fuzzyScore("xyz", "Deploy staging"); ok {
+		t.Error("Expected 'xyz' not to match 'Deploy staging'")
+	}
+
+	substring, _ := fuzzyScore("stag", "Deploy staging")
+	scattered, _ := fuzzyScore("stag", "Set up a tag")
+	if substring <= scattered {
+		t.Errorf("Expected a substring match to outscore a scattered one, got %d <= %d", substring, scattered)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+
+	idNotes := addTestTask(t, db, "Write release notes")
+	idStaging := addTestTask(t, db, "Deploy staging")
+	idProd := addTestTask(t, db, "Deploy production")
+
+	resolve := func(ref, input string) (int, string, error) {
+		var out strings.Builder
+		id, err := todos.Resolve(ref, strings.NewReader(input), &out)
+		return id, out.String(), err
+	}
+
+	t.Run("Numeric ID", func(t *testing.T) {
+		id, _, err := resolve("42", "")
+		if err != nil || id != 42 {
+			t.Errorf("Expected 42, got %d (err: %v)", id, err)
+		}
+	})
+
+	t.Run("Unambiguous text", func(t *testing.T) {
+		id, out, err := resolve("notes", "")
+		if err != nil || id != idNotes {
+			t.Errorf("Expected %d, got %d (err: %v)", idNotes, id, err)
+		}
+		if out != "" {
+			t.Errorf("Expected no picker for an unambiguous match, got %q", out)
+		}
+	})
+
+	t.Run("Ambiguous text asks", func(t *testing.T) {
+		id, out, err := resolve("deploy", "2\n")
+		if err != nil {
+			t.Fatalf("Resolve failed: %v", err)
+		}
+		if !strings.Contains(out, "Deploy staging") || !strings.Contains(out, "Deploy production") {
+			t.Errorf("Expected both deploy todos in the picker, got %q", out)
+		}
+		if id != idStaging && id != idProd {
+			t.Errorf("Expected one of the deploy todos, got %d", id)
+		}
+	})
+
+	t.Run("Picker narrows by text", func(t *testing.T) {
+		id, _, err := resolve("deploy", "prod\n")
+		if err != nil || id != idProd {
+			t.Errorf("Expected %d, got %d (err: %v)", idProd, id, err)
+		}
+	})
+
+	t.Run("Empty reference picks from everything", func(t *testing.T) {
+		id, out, err := resolve("", "1\n")
+		if err != nil {
+			t.Fatalf("Resolve failed: %v", err)
+		}
+		if strings.Count(out, ")") != 3 {
+			t.Errorf("Expected all 3 pending todos in the picker, got %q", out)
+		}
+		if id == 0 {
+			t.Error("Expected a todo to be picked")
+		}
+	})
+
+	t.Run("No match", func(t *testing.T) {
+		_, _, err := resolve("groceries", "")
+		if !errors.Is(err, ErrNoMatch) {
+			t.Errorf("Expected ErrNoMatch, got %v", err)
+		}
+	})
+
+	t.Run("Picker without an answer", func(t *testing.T) {
+		if _, _, err := resolve("deploy", ""); err == nil {
+			t.Error("Expected an error when nothing is picked")
+		}
+	})
+}
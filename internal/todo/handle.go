@@ -0,0 +1,118 @@
+package todo
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Handles are never shorter than this, so they stay readable as they grow
+const minHandleLength = 4
+
+// Random (version 4) UUID. Unlike the rowid it stays the same when todos are
+// exported, imported or merged between machines
+func newUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("reading random bytes: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Normalizes a UID or a prefix of one for comparison
+func compactUID(uid string) string {
+	return strings.ToLower(strings.ReplaceAll(uid, "-", ""))
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// Shortest prefix of every todo's UID that no other todo shares and that
+// has a letter in it, keyed by ID
+func (db *DB) Handles() (map[int]string, error) {
+	return db.handles(false)
+}
+
+// Same as Handles for the todos in the trash, unique among those only
+func (db *DB) TrashHandles() (map[int]string, error) {
+	return db.handles(true)
+}
+
+func (db *DB) handles(trashed bool) (map[int]string, error) {
+	rows, err := db.Query(`SELECT id, uid FROM todos WHERE (deleted_at IS NOT NULL) = ?`, trashed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type entry struct {
+		id  int
+		uid string
+	}
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.id, &e.uid); err != nil {
+			return nil, err
+		}
+		e.uid = compactUID(e.uid)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].uid < entries[j].uid })
+
+	// In sorted order a prefix only has to differ from the neighbours
+	handles := make(map[int]string, len(entries))
+	for i, e := range entries {
+		length := minHandleLength
+		if i > 0 {
+			length = max(length, commonPrefix(e.uid, entries[i-1].uid)+1)
+		}
+		if i < len(entries)-1 {
+			length = max(length, commonPrefix(e.uid, entries[i+1].uid)+1)
+		}
+		// An all digit handle would read as a row ID
+		for length < len(e.uid) && !strings.ContainsAny(e.uid[:length], "abcdef") {
+			length++
+		}
+		handles[e.id] = e.uid[:min(length, len(e.uid))]
+	}
+	return handles, nil
+}
+
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// IDs of the todos whose UID starts with prefix
+func (db *DB) FindByUIDPrefix(prefix string) ([]int, error) {
+	return db.findByUIDPrefix(prefix, false)
+}
+
+func (db *DB) findByUIDPrefix(prefix string, trashed bool) ([]int, error) {
+	return db.ids(`
+		SELECT id FROM todos
+		WHERE replace(uid, '-', '') LIKE ? || '%' AND (deleted_at IS NOT NULL) = ?
+		`, compactUID(prefix), trashed)
+}
+
+func (db *DB) todoExists(id int) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM todos WHERE id = ? AND deleted_at IS NULL)`, id).Scan(&exists)
+	return exists, err
+}
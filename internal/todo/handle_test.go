@@ -0,0 +1,127 @@
+package todo
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNewUID(t *testing.T) {
+	uuidV4 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		uid := newUID()
+		if !uuidV4.MatchString(uid) {
+			t.Fatalf("newUID() = %q, not a version 4 UUID", uid)
+		}
+		if seen[uid] {
+			t.Fatalf("newUID() returned %q twice", uid)
+		}
+		seen[uid] = true
+	}
+}
+
+func TestHandles(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// Fixed UIDs sharing long prefixes
+	uids := []string{
+		"abcd1234-0000-4000-8000-000000000000",
+		"abcd1299-0000-4000-8000-000000000000",
+		"ffff0000-0000-4000-8000-000000000000",
+	}
+	ids := make([]int, len(uids))
+	for n, uid := range uids {
+		id, err := insertTodo(db, item{Task: "Task " + uid[:4], UID: uid})
+		if err != nil {
+			t.Fatalf("insertTodo failed: %v", err)
+		}
+		ids[n] = id
+	}
+
+	handles, err := db.Handles()
+	if err != nil {
+		t.Fatalf("Handles failed: %v", err)
+	}
+	expected := []string{"abcd123", "abcd129", "ffff"}
+	for n, id := range ids {
+		if handles[id] != expected[n] {
+			t.Errorf("Expected handle %q for %s, got %q", expected[n], uids[n], handles[id])
+		}
+	}
+
+	todos := NewTodos(db)
+	resolve := func(ref string) (int, error) {
+		return todos.Resolve(ref, strings.NewReader(""), &strings.Builder{})
+	}
+
+	t.Run("Handle", func(t *testing.T) {
+		id, err := resolve("abcd129")
+		if err != nil || id != ids[1] {
+			t.Errorf("Expected %d, got %d (err: %v)", ids[1], id, err)
+		}
+	})
+
+	t.Run("Full UID", func(t *testing.T) {
+		id, err := resolve(uids[2])
+		if err != nil || id != ids[2] {
+			t.Errorf("Expected %d, got %d (err: %v)", ids[2], id, err)
+		}
+	})
+
+	t.Run("Ambiguous prefix", func(t *testing.T) {
+		if _, err := resolve("abcd"); err == nil {
+			t.Error("Expected an error for a prefix shared by two todos")
+		}
+	})
+
+	t.Run("Row ID wins over a numeric handle", func(t *testing.T) {
+		id, err := resolve("1")
+		if err != nil || id != ids[0] {
+			t.Errorf("Expected %d, got %d (err: %v)", ids[0], id, err)
+		}
+	})
+}
+
+func TestHandlesResolveToTheirTodo(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// UIDs starting with digits, the first one's short prefix reads as #12
+	uids := []string{
+		"0012a000-0000-4000-8000-000000000000",
+		"12345678-9abc-4000-8000-000000000000",
+		"99999999-9999-4999-8999-99999999999f",
+	}
+	for _, uid := range uids {
+		if _, err := insertTodo(db, item{Task: "Task " + uid[:4], UID: uid}); err != nil {
+			t.Fatalf("insertTodo failed: %v", err)
+		}
+	}
+	for i := 0; i < 12; i++ {
+		addTestTask(t, db, "Filler")
+	}
+
+	handles, err := db.Handles()
+	if err != nil {
+		t.Fatalf("Handles failed: %v", err)
+	}
+	if handles[1] != "0012a" {
+		t.Errorf("Expected the handle extended to its first letter, got %q", handles[1])
+	}
+
+	todos := NewTodos(db)
+	for id, handle := range handles {
+		got, err := todos.Resolve(handle, strings.NewReader(""), &strings.Builder{})
+		if err != nil || got != id {
+			t.Errorf("Handle %q of #%d resolved to #%d (err: %v)", handle, id, got, err)
+		}
+	}
+
+	// Digits alone are an ID, never the start of a UID
+	id, err := todos.Resolve("1234", strings.NewReader(""), &strings.Builder{})
+	if err != nil || id != 1234 {
+		t.Errorf("Expected 1234 passed on as an ID, got %d (err: %v)", id, err)
+	}
+}
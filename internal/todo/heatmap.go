@@ -0,0 +1,113 @@
+package todo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const heatmapCell = "■"
+
+// Completions per day over the last weeks weeks, keyed by YYYY-MM-DD. The
+// map covers whole weeks, starting on the Monday returned
+func (t *Todos) Heatmap(now time.Time, weeks int, project string) (map[string]int, time.Time, error) {
+	thisWeek, _, _ := PeriodBounds(PeriodWeek, now.In(t.db.location), false)
+	start := thisWeek.AddDate(0, 0, -7*(weeks-1))
+
+	completed, err := t.completedBetween(start, time.Time{})
+	if err != nil {
+		return nil, start, err
+	}
+
+	counts := map[string]int{}
+	for _, item := range completed {
+		if project != "" && item.Project != project {
+			continue
+		}
+		counts[item.CompletedAt.Format(dayFormat)]++
+	}
+	return counts, start, nil
+}
+
+// Prints a contribution style calendar of completed todos, one column per
+// week and one row per weekday
+func (t *Todos) PrintHeatmap(now time.Time, weeks int, project string) error {
+	counts, start, err := t.Heatmap(now, weeks, project)
+	if err != nil {
+		return err
+	}
+	fmt.Print(renderHeatmap(counts, start, weeks, now.In(t.db.location)))
+
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	what := "todos"
+	if project != "" {
+		what = project + " todos"
+	}
+	fmt.Printf("%d %s completed in the last %d weeks\n", total, what, weeks)
+	return nil
+}
+
+func renderHeatmap(counts map[string]int, start time.Time, weeks int, now time.Time) string {
+	most := 0
+	for _, n := range counts {
+		most = max(most, n)
+	}
+
+	var b strings.Builder
+
+	// Month names over the first week they start in, as long as they fit
+	labels := []rune(strings.Repeat(" ", 4+weeks*2+3))
+	lastMonth := time.Month(0)
+	for w := 0; w < weeks; w++ {
+		week := start.AddDate(0, 0, 7*w)
+		end := week.AddDate(0, 0, 6)
+		if end.Month() == lastMonth {
+			continue
+		}
+		lastMonth = end.Month()
+		col := 4 + w*2
+		name := []rune(end.Format("Jan"))
+		if col+len(name) > len(labels) || (col > 4 && labels[col-1] != ' ') {
+			continue
+		}
+		copy(labels[col:], name)
+	}
+	b.WriteString(strings.TrimRight(string(labels), " ") + "\n")
+
+	today := startOfDay(now)
+	for row := 0; row < 7; row++ {
+		name := ""
+		if row%2 == 0 {
+			name = start.AddDate(0, 0, row).Format("Mon")
+		}
+		fmt.Fprintf(&b, "%-3s ", name)
+		for w := 0; w < weeks; w++ {
+			day := start.AddDate(0, 0, 7*w+row)
+			if day.After(today) {
+				break
+			}
+			b.WriteString(gradient(heatLevel(counts[day.Format(dayFormat)], most), heatmapCell) + " ")
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n    Less ")
+	for level := range Gradient {
+		b.WriteString(gradient(level, heatmapCell) + " ")
+	}
+	b.WriteString("More\n")
+	return b.String()
+}
+
+// Scales a count to a gradient step, anything done gets at least the first
+// colored step
+func heatLevel(n, most int) int {
+	if n <= 0 || most <= 0 {
+		return 0
+	}
+	steps := len(Gradient) - 1
+	return max(1, (n*steps+most-1)/most)
+}
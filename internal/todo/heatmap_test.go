@@ -0,0 +1,83 @@
+package todo
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeatLevel(t *testing.T) {
+	tests := []struct{ n, most, want int }{
+		{0, 10, 0},
+		{1, 10, 1},
+		{5, 10, 2},
+		{10, 10, 4},
+		{3, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := heatLevel(tt.n, tt.most); got != tt.want {
+			t.Errorf("heatLevel(%d, %d) = %d, want %d", tt.n, tt.most, got, tt.want)
+		}
+	}
+}
+
+func TestHeatmap(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	db.SetLocation(time.UTC)
+	todos := NewTodos(db)
+
+	// Wednesday
+	now := time.Date(2024, 9, 18, 12, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		project string
+		at      time.Time
+	}{
+		{"website", time.Date(2024, 9, 16, 10, 0, 0, 0, time.UTC)},
+		{"website", time.Date(2024, 9, 16, 11, 0, 0, 0, time.UTC)},
+		{"", time.Date(2024, 9, 10, 10, 0, 0, 0, time.UTC)},
+		// Before the first week shown
+		{"website", time.Date(2024, 8, 1, 10, 0, 0, 0, time.UTC)},
+	} {
+		id := addTestTask(t, db, "Task")
+		if err := db.SetProject(id, c.project); err != nil {
+			t.Fatalf("SetProject failed: %v", err)
+		}
+		completeAt(t, db, id, c.at)
+	}
+
+	counts, start, err := todos.Heatmap(now, 2, "")
+	if err != nil {
+		t.Fatalf("Heatmap failed: %v", err)
+	}
+	if !start.Equal(time.Date(2024, 9, 9, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the map to start on Monday the 9th, got %v", start)
+	}
+	if counts["2024-09-16"] != 2 || counts["2024-09-10"] != 1 || len(counts) != 2 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+
+	counts, _, _ = todos.Heatmap(now, 2, "website")
+	if counts["2024-09-16"] != 2 || len(counts) != 1 {
+		t.Errorf("Expected only website todos, got %v", counts)
+	}
+
+	out := renderHeatmap(counts, start, 2, now)
+	lines := strings.Split(out, "\n")
+	if !strings.HasPrefix(lines[0], "    Sep") {
+		t.Errorf("Expected the month above the first column, got %q", lines[0])
+	}
+	// Mon to Wed have both weeks, the rest of this week is still ahead
+	if n := strings.Count(lines[1], heatmapCell); n != 2 {
+		t.Errorf("Expected 2 cells on Monday, got %d", n)
+	}
+	if n := strings.Count(lines[4], heatmapCell); n != 1 {
+		t.Errorf("Expected 1 cell on Thursday, got %d", n)
+	}
+	if !strings.Contains(lines[1], gradient(4, heatmapCell)) {
+		t.Errorf("Expected the busiest day in the brightest color, got %q", lines[1])
+	}
+	if !strings.Contains(out, "Less") || !strings.Contains(out, "More") {
+		t.Error("Expected a legend")
+	}
+}
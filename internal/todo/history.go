@@ -0,0 +1,324 @@
+package todo
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"os/user"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/alexeyco/simpletable"
+)
+
+// A single field of a todo changing. Adding a todo is recorded as the field
+// "created" and purging it as "purged", dependencies as "blocked_by" with the
+// blocker's ID as the value
+type change struct {
+	ID        int
+	TodoID    int
+	Task      string
+	Field     string
+	Old       string
+	New       string
+	ChangedBy string
+	ChangedAt time.Time
+}
+
+// Who changes are recorded as, the login of whoever runs the CLI
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// Records changes under another name than the current user
+func (db *DB) SetUser(name string) {
+	db.user = name
+}
+
+// Runs a change to the given todos in a transaction and appends whatever it
+// changed to their history, and to the journal when it is part of an
+// operation. Todos the change adds are picked up on their own
+func (db *DB) tracked(ids []int, change func(tx *sql.Tx) error) error {
+	if db.operation != nil {
+		ids = append(ids, db.operation.ids...)
+	}
+	return db.transaction(func(tx *sql.Tx) error {
+		var last int
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM todos`).Scan(&last); err != nil {
+			return err
+		}
+		before, err := takeSnapshot(tx, ids)
+		if err != nil {
+			return err
+		}
+
+		if err := change(tx); err != nil {
+			return err
+		}
+
+		added, err := idsAfter(tx, last)
+		if err != nil {
+			return err
+		}
+		after, err := takeSnapshot(tx, append(ids, added...))
+		if err != nil {
+			return err
+		}
+		if err := db.recordChanges(tx, before, after); err != nil {
+			return err
+		}
+		if db.operation == nil || reflect.DeepEqual(before, after) {
+			return nil
+		}
+		name := db.operation.name
+		return recordOperation(tx, name, describe(name, before, after), before, after)
+	})
+}
+
+// Appends the differences between two snapshots to the history
+func (db *DB) recordChanges(tx *sql.Tx, before, after snapshot) error {
+	now := utcNow()
+	for _, c := range diffSnapshots(before, after) {
+		_, err := tx.Exec(`
+			INSERT INTO history
+			(todo_id, field, old_value, new_value, changed_by, changed_at) VALUES (?, ?, ?, ?, ?, ?)
+			`, c.TodoID, c.Field, c.Old, c.New, db.user, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Field by field changes from one snapshot to the other
+func diffSnapshots(before, after snapshot) []change {
+	old := map[int]todoRow{}
+	for _, row := range before.Todos {
+		old[row.ID] = row
+	}
+	current := map[int]bool{}
+
+	var changes []change
+	for _, row := range after.Todos {
+		current[row.ID] = true
+		prev, ok := old[row.ID]
+		if !ok {
+			changes = append(changes, change{TodoID: row.ID, Field: "created", New: row.Task})
+			continue
+		}
+		type field struct{ name, old, new string }
+		fields := []field{
+			{"task", prev.Task, row.Task},
+			{"status", prev.Status, row.Status},
+			{"project", prev.Project, row.Project},
+			{"estimate", prev.Estimate, row.Estimate},
+			{"recurrence", prev.Recurrence, row.Recurrence},
+			{"due_at", formatStored(prev.DueAt), formatStored(row.DueAt)},
+			{"deleted_at", formatStored(prev.DeletedAt), formatStored(row.DeletedAt)},
+		}
+		// done and completed_at follow the status, they only get an entry of
+		// their own when they change without it, like doctor repairs do
+		if prev.Status == row.Status {
+			fields = append(fields,
+				field{"done", strconv.FormatBool(prev.Done), strconv.FormatBool(row.Done)},
+				field{"completed_at", formatStored(prev.CompletedAt), formatStored(row.CompletedAt)})
+		}
+		for _, f := range fields {
+			if f.old != f.new {
+				changes = append(changes, change{TodoID: row.ID, Field: f.name, Old: f.old, New: f.new})
+			}
+		}
+	}
+	purged := map[int]bool{}
+	for _, row := range before.Todos {
+		if !current[row.ID] {
+			purged[row.ID] = true
+			changes = append(changes, change{TodoID: row.ID, Field: "purged", Old: row.Task})
+		}
+	}
+
+	deps := func(s snapshot) map[[2]int]bool {
+		set := map[[2]int]bool{}
+		for _, d := range s.Dependencies {
+			set[d] = true
+		}
+		return set
+	}
+	was, is := deps(before), deps(after)
+	for _, d := range after.Dependencies {
+		if !was[d] {
+			changes = append(changes, change{TodoID: d[1], Field: "blocked_by", New: strconv.Itoa(d[0])})
+		}
+	}
+	for _, d := range before.Dependencies {
+		// Purging a todo takes its dependencies along, that says enough
+		if !is[d] && !purged[d[0]] && !purged[d[1]] {
+			changes = append(changes, change{TodoID: d[1], Field: "blocked_by", Old: strconv.Itoa(d[0])})
+		}
+	}
+	return changes
+}
+
+// Timestamps go into the history as RFC3339 in UTC
+func formatStored(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Everything recorded about a todo, oldest first
+func (db *DB) GetHistory(id int) ([]change, error) {
+	return db.scanChanges(`
+		SELECT h.id, h.todo_id, COALESCE(t.task, a.task, ''), h.field, h.old_value, h.new_value, h.changed_by, h.changed_at
+		FROM history h
+		LEFT JOIN todos t ON t.id = h.todo_id
+		LEFT JOIN archive a ON a.id = h.todo_id
+		WHERE h.todo_id = ?
+		ORDER BY h.id;
+		`, id)
+}
+
+// Changes to any todo within [since, until), oldest first. Zero times leave
+// that side open
+func (db *DB) GetChanges(since, until time.Time) ([]change, error) {
+	if until.IsZero() {
+		until = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return db.scanChanges(`
+		SELECT h.id, h.todo_id, COALESCE(t.task, a.task, ''), h.field, h.old_value, h.new_value, h.changed_by, h.changed_at
+		FROM history h
+		LEFT JOIN todos t ON t.id = h.todo_id
+		LEFT JOIN archive a ON a.id = h.todo_id
+		WHERE h.changed_at >= ? AND h.changed_at < ?
+		ORDER BY h.id;
+		`, since.UTC(), until.UTC())
+}
+
+func (db *DB) scanChanges(query string, args ...interface{}) ([]change, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []change
+	for rows.Next() {
+		var c change
+		if err := rows.Scan(&c.ID, &c.TodoID, &c.Task, &c.Field, &c.Old, &c.New, &c.ChangedBy, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		c.ChangedAt = c.ChangedAt.In(db.location)
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// What happened in a change, like "status todo → done"
+func (c change) describe(loc *time.Location) string {
+	value := func(v string) string {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t.In(loc).Format("2006-01-02 15:04")
+		}
+		return v
+	}
+
+	switch c.Field {
+	case "created":
+		return fmt.Sprintf("created %q", c.New)
+	case "purged":
+		return fmt.Sprintf("purged %q", c.Old)
+	case "archived":
+		return "archived"
+	case "deleted_at":
+		if c.New == "" {
+			return "restored from the trash"
+		}
+		return "moved to the trash"
+	case "blocked_by":
+		if c.New == "" {
+			return "no longer blocked by #" + c.Old
+		}
+		return "blocked by #" + c.New
+	}
+
+	old, new := value(c.Old), value(c.New)
+	if old == "" {
+		old = "(none)"
+	}
+	if new == "" {
+		new = "(none)"
+	}
+	return fmt.Sprintf("%s %s → %s", c.Field, old, new)
+}
+
+// Prints how a todo evolved
+func (t *Todos) PrintHistory(id int) error {
+	changes, err := t.db.GetHistory(id)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return fmt.Errorf("%w: no history for %d", ErrNotFound, id)
+	}
+
+	task := changes[0].Task
+	if task == "" {
+		// Purged, the first entry still has the name it was added with
+		task = changes[0].New
+	}
+	fmt.Printf("%s %s\n", task, gray(fmt.Sprintf("#%d", id)))
+	return t.printChanges(changes, false)
+}
+
+// Prints every change made within [since, until)
+func (t *Todos) PrintLog(since, until time.Time) error {
+	changes, err := t.db.GetChanges(since, until)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Println("No changes recorded.")
+		return nil
+	}
+	return t.printChanges(changes, true)
+}
+
+func (t *Todos) printChanges(changes []change, withTodo bool) error {
+	table := simpletable.New()
+	header := []*simpletable.Cell{
+		{Align: simpletable.AlignCenter, Text: "When"},
+		{Align: simpletable.AlignCenter, Text: "Who"},
+	}
+	if withTodo {
+		header = append(header, &simpletable.Cell{Align: simpletable.AlignCenter, Text: "Todo"})
+	}
+	header = append(header, &simpletable.Cell{Align: simpletable.AlignCenter, Text: "Change"})
+	table.Header = &simpletable.Header{Cells: header}
+
+	for _, c := range changes {
+		row := []*simpletable.Cell{
+			{Text: c.ChangedAt.Format("2006-01-02 15:04")},
+			{Text: c.ChangedBy},
+		}
+		if withTodo {
+			name := c.Task
+			if name == "" {
+				name = gray("(purged)")
+			}
+			row = append(row, &simpletable.Cell{Text: fmt.Sprintf("%d %s", c.TodoID, name)})
+		}
+		row = append(row, &simpletable.Cell{Text: c.describe(t.db.location)})
+		table.Body.Cells = append(table.Body.Cells, row)
+	}
+	table.SetStyle(simpletable.StyleUnicode)
+	table.Println()
+	return nil
+}
@@ -0,0 +1,118 @@
+package todo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	db.SetUser("jose")
+	todos := NewTodos(db)
+
+	if err := todos.AddWithOptions("Write the report", AddOptions{Estimate: "2h"}); err != nil {
+		t.Fatalf("AddWithOptions failed: %v", err)
+	}
+	id := addTestTask(t, db, "Review the report")
+	report := id - 1
+
+	if err := todos.Edit(report, "Write the quarterly report"); err != nil {
+		t.Fatalf("Edit failed: %v", err)
+	}
+	if err := todos.SetProject(report, "work"); err != nil {
+		t.Fatalf("SetProject failed: %v", err)
+	}
+	if err := todos.Block(id, report); err != nil {
+		t.Fatalf("Block failed: %v", err)
+	}
+	if _, err := todos.Complete(report); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if _, err := todos.Undo(1); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+
+	changes, err := db.GetHistory(report)
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	want := []struct{ field, old, new string }{
+		{"created", "", "Write the report"},
+		{"task", "Write the report", "Write the quarterly report"},
+		{"project", "", "work"},
+		{"status", StatusTodo, StatusDone},
+		{"status", StatusDone, StatusTodo},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %+v", len(want), changes)
+	}
+	for i, w := range want {
+		c := changes[i]
+		if c.Field != w.field || c.Old != w.old || c.New != w.new {
+			t.Errorf("Change %d: expected %s %q → %q, got %s %q → %q", i, w.field, w.old, w.new, c.Field, c.Old, c.New)
+		}
+		if c.ChangedBy != "jose" || c.Task != "Write the quarterly report" {
+			t.Errorf("Change %d: unexpected author %q or task %q", i, c.ChangedBy, c.Task)
+		}
+	}
+
+	blocked, err := db.GetHistory(id)
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(blocked) != 2 || blocked[1].Field != "blocked_by" || blocked[1].New != "1" {
+		t.Errorf("Expected the dependency in the history of the blocked todo, got %+v", blocked)
+	}
+	if got := blocked[1].describe(time.UTC); got != "blocked by #1" {
+		t.Errorf("Unexpected description %q", got)
+	}
+
+	// The history outlives a purge, and can't be rewritten
+	if err := todos.Delete(id); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := todos.Purge(0); err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	blocked, _ = db.GetHistory(id)
+	if last := blocked[len(blocked)-1]; last.Field != "purged" || last.Task != "" {
+		t.Errorf("Expected the purge to be the last entry, got %+v", last)
+	}
+	if _, err := db.Exec(`UPDATE history SET changed_by = 'someone else'`); err == nil {
+		t.Error("Expected the history to refuse updates")
+	}
+	if _, err := db.Exec(`DELETE FROM history`); err == nil {
+		t.Error("Expected the history to refuse deletes")
+	}
+}
+
+func TestChangesWindow(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+
+	if err := todos.Add("Old"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	since := time.Now()
+	if err := todos.Add("New"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	changes, err := db.GetChanges(since, time.Time{})
+	if err != nil {
+		t.Fatalf("GetChanges failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].New != "New" {
+		t.Errorf("Expected only the second add, got %+v", changes)
+	}
+
+	changes, err = db.GetChanges(time.Time{}, since)
+	if err != nil {
+		t.Fatalf("GetChanges failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].New != "Old" {
+		t.Errorf("Expected only the first add, got %+v", changes)
+	}
+}
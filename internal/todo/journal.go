@@ -0,0 +1,474 @@
+package todo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// A todo row as stored, trashed or not
+type todoRow struct {
+	ID          int        `json:"id"`
+	UID         string     `json:"uid"`
+	Task        string     `json:"task"`
+	Done        bool       `json:"done"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Recurrence  string     `json:"recurrence"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	Status      string     `json:"status"`
+	Project     string     `json:"project"`
+	Estimate    string     `json:"estimate"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+// A timer running at the time of a snapshot
+type openSession struct {
+	ID        int       `json:"id"`
+	TodoID    int       `json:"todo_id"`
+	StartedAt time.Time `json:"started_at"`
+	Kind      string    `json:"kind"`
+}
+
+// The todos an operation touched, their dependencies and running timers, as
+// they were before or after it. A todo missing from it did not exist at that point
+type snapshot struct {
+	Todos        []todoRow     `json:"todos"`
+	Dependencies [][2]int      `json:"dependencies"`
+	Running      []openSession `json:"running,omitempty"`
+}
+
+// IDs of every todo in either snapshot
+func scope(snapshots ...snapshot) []int {
+	seen := map[int]bool{}
+	var ids []int
+	for _, s := range snapshots {
+		for _, row := range s.Todos {
+			if !seen[row.ID] {
+				seen[row.ID] = true
+				ids = append(ids, row.ID)
+			}
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// A journaled change, undone when UndoneAt is set
+type operation struct {
+	ID        int
+	Name      string
+	Summary   string
+	Before    snapshot
+	After     snapshot
+	CreatedAt time.Time
+	UndoneAt  time.Time
+}
+
+// A transaction, or a plain *sql.DB. DB.Query returns its own rows, see
+// DB.ids for that one
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func intArgs(ids []int) []interface{} {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
+
+// Takes a snapshot of the given todos and every dependency they are part of
+func takeSnapshot(q querier, ids []int) (snapshot, error) {
+	var s snapshot
+	if len(ids) == 0 {
+		return s, nil
+	}
+	in := placeholders(len(ids))
+
+	rows, err := q.Query(`
+		SELECT id, uid, task, done, created_at, completed_at, recurrence, due_at, status, project, estimate, deleted_at
+		FROM todos
+		WHERE id IN (`+in+`)
+		ORDER BY id;
+		`, intArgs(ids)...)
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var row todoRow
+		var completedAt, dueAt, deletedAt sql.NullTime
+		if err := rows.Scan(&row.ID, &row.UID, &row.Task, &row.Done, &row.CreatedAt, &completedAt, &row.Recurrence, &dueAt, &row.Status, &row.Project, &row.Estimate, &deletedAt); err != nil {
+			return s, err
+		}
+		row.CreatedAt = row.CreatedAt.UTC()
+		row.CompletedAt = nullTime(completedAt)
+		row.DueAt = nullTime(dueAt)
+		row.DeletedAt = nullTime(deletedAt)
+		s.Todos = append(s.Todos, row)
+	}
+	if err := rows.Err(); err != nil {
+		return s, err
+	}
+
+	deps, err := q.Query(`
+		SELECT blocker_id, blocked_id
+		FROM dependencies
+		WHERE blocker_id IN (`+in+`) OR blocked_id IN (`+in+`)
+		ORDER BY blocker_id, blocked_id;
+		`, append(intArgs(ids), intArgs(ids)...)...)
+	if err != nil {
+		return s, err
+	}
+	defer deps.Close()
+	for deps.Next() {
+		var d [2]int
+		if err := deps.Scan(&d[0], &d[1]); err != nil {
+			return s, err
+		}
+		s.Dependencies = append(s.Dependencies, d)
+	}
+	if err := deps.Err(); err != nil {
+		return s, err
+	}
+
+	running, err := q.Query(`
+		SELECT id, todo_id, started_at, kind
+		FROM sessions
+		WHERE ended_at IS NULL AND todo_id IN (`+in+`);
+		`, intArgs(ids)...)
+	if err != nil {
+		return s, err
+	}
+	defer running.Close()
+	for running.Next() {
+		var o openSession
+		if err := running.Scan(&o.ID, &o.TodoID, &o.StartedAt, &o.Kind); err != nil {
+			return s, err
+		}
+		o.StartedAt = o.StartedAt.UTC()
+		s.Running = append(s.Running, o)
+	}
+	return s, running.Err()
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := t.Time.UTC()
+	return &utc
+}
+
+// Puts the todos in ids back the way target has them: rows missing from it
+// are removed along with everything recorded about them, the others are
+// written back as they were. Timers target doesn't have running are dropped,
+// and the ones it has are put back unless their session was stopped since or
+// another timer runs. Status changes are recorded as transitions so the
+// charts follow along, and the history gets what changed
+func (db *DB) applySnapshot(tx *sql.Tx, ids []int, target snapshot) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := unarchive(tx, ids); err != nil {
+		return err
+	}
+	current, err := takeSnapshot(tx, ids)
+	if err != nil {
+		return err
+	}
+	status := map[int]string{}
+	for _, row := range current.Todos {
+		status[row.ID] = row.Status
+	}
+
+	wanted := map[int]bool{}
+	now := utcNow()
+	for _, row := range target.Todos {
+		wanted[row.ID] = true
+		if from, ok := status[row.ID]; ok {
+			_, err = tx.Exec(`
+				UPDATE todos
+				SET uid = ?, task = ?, done = ?, created_at = ?, completed_at = ?, recurrence = ?,
+					due_at = ?, status = ?, project = ?, estimate = ?, deleted_at = ?
+				WHERE id = ?
+				`, row.UID, row.Task, row.Done, row.CreatedAt, row.CompletedAt, row.Recurrence,
+				row.DueAt, row.Status, row.Project, row.Estimate, row.DeletedAt, row.ID)
+			if err == nil && from != row.Status {
+				_, err = tx.Exec(`
+					INSERT INTO status_transitions
+					(todo_id, from_status, to_status, changed_at) VALUES (?, ?, ?, ?)
+					`, row.ID, from, row.Status, now)
+			}
+		} else {
+			_, err = tx.Exec(`
+				INSERT INTO todos
+				(id, uid, task, done, created_at, completed_at, recurrence, due_at, status, project, estimate, deleted_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				`, row.ID, row.UID, row.Task, row.Done, row.CreatedAt, row.CompletedAt, row.Recurrence,
+				row.DueAt, row.Status, row.Project, row.Estimate, row.DeletedAt)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	in := placeholders(len(ids))
+	_, err = tx.Exec(`DELETE FROM dependencies WHERE blocker_id IN (`+in+`) OR blocked_id IN (`+in+`)`,
+		append(intArgs(ids), intArgs(ids)...)...)
+	if err != nil {
+		return err
+	}
+	for _, d := range target.Dependencies {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO dependencies (blocker_id, blocked_id) VALUES (?, ?)`, d[0], d[1]); err != nil {
+			return err
+		}
+	}
+
+	keep := map[int]bool{}
+	for _, o := range target.Running {
+		keep[o.ID] = true
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO sessions (id, todo_id, started_at, kind) VALUES (?, ?, ?, ?)
+			`, o.ID, o.TodoID, o.StartedAt, o.Kind)
+		if err != nil {
+			return err
+		}
+	}
+	for _, o := range current.Running {
+		if keep[o.ID] {
+			continue
+		}
+		if _, err := tx.Exec(`DELETE FROM sessions WHERE id = ?`, o.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range ids {
+		if _, ok := status[id]; !ok || wanted[id] {
+			continue
+		}
+		_, err := tx.Exec(`
+			DELETE FROM status_transitions WHERE todo_id = ?;
+			DELETE FROM sessions WHERE todo_id = ?;
+			DELETE FROM todos WHERE id = ?;
+			`, id, id, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	after, err := takeSnapshot(tx, ids)
+	if err != nil {
+		return err
+	}
+	return db.recordChanges(tx, current, after)
+}
+
+func idsAfter(q querier, id int) ([]int, error) {
+	return idsOf(q, `SELECT id FROM todos WHERE id > ? ORDER BY id`, id)
+}
+
+// Runs a query selecting a single column of IDs
+func idsOf(q querier, query string, args ...interface{}) ([]int, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanIDs(rows)
+}
+
+// idsOf for queries outside a transaction
+func (db *DB) ids(query string, args ...interface{}) ([]int, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanIDs(rows)
+}
+
+func scanIDs(rows interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+}) ([]int, error) {
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Journals an operation. Anything undone before it can't be redone anymore
+func recordOperation(tx *sql.Tx, name, summary string, before, after snapshot) error {
+	b, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+	a, err := json.Marshal(after)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM operations WHERE undone_at IS NOT NULL`); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO operations (name, summary, before, after, created_at) VALUES (?, ?, ?, ?, ?)
+		`, name, summary, string(b), string(a), utcNow())
+	return err
+}
+
+// Reverts the last n operations in one transaction, newest first, and
+// returns them in the order they were undone
+func (db *DB) UndoOperations(n int) ([]operation, error) {
+	return db.replay(`
+		SELECT id, name, summary, before, after, created_at, undone_at
+		FROM operations
+		WHERE undone_at IS NULL
+		ORDER BY id DESC
+		LIMIT ?;
+		`, n, true)
+}
+
+// Applies the last n undone operations again, oldest first
+func (db *DB) RedoOperations(n int) ([]operation, error) {
+	return db.replay(`
+		SELECT id, name, summary, before, after, created_at, undone_at
+		FROM operations
+		WHERE undone_at IS NOT NULL
+		ORDER BY id
+		LIMIT ?;
+		`, n, false)
+}
+
+func (db *DB) replay(query string, n int, undo bool) ([]operation, error) {
+	var ops []operation
+	err := db.transaction(func(tx *sql.Tx) error {
+		var err error
+		ops, err = scanOperations(tx, query, n)
+		if err != nil {
+			return err
+		}
+		if len(ops) == 0 {
+			if undo {
+				return ErrNothingToUndo
+			}
+			return ErrNothingToRedo
+		}
+
+		now := utcNow()
+		for i, op := range ops {
+			target, undoneAt := op.After, sql.NullTime{}
+			if undo {
+				target, undoneAt = op.Before, sql.NullTime{Time: now, Valid: true}
+			}
+			if err := db.applySnapshot(tx, scope(op.Before, op.After), target); err != nil {
+				return fmt.Errorf("operation %d (%s): %w", op.ID, op.Summary, err)
+			}
+			if _, err := tx.Exec(`UPDATE operations SET undone_at = ? WHERE id = ?`, undoneAt, op.ID); err != nil {
+				return err
+			}
+			ops[i].CreatedAt = op.CreatedAt.In(db.location)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+func scanOperations(q querier, query string, args ...interface{}) ([]operation, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ops []operation
+	for rows.Next() {
+		var op operation
+		var before, after string
+		var undoneAt sql.NullTime
+		if err := rows.Scan(&op.ID, &op.Name, &op.Summary, &before, &after, &op.CreatedAt, &undoneAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(before), &op.Before); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(after), &op.After); err != nil {
+			return nil, err
+		}
+		if undoneAt.Valid {
+			op.UndoneAt = undoneAt.Time
+		}
+		ops = append(ops, op)
+	}
+	return ops, rows.Err()
+}
+
+// A change being journaled, see Todos.journal
+type pendingOperation struct {
+	name string
+	ids  []int
+}
+
+// Runs a change to the given todos and journals it so it can be undone. The
+// change gets a DB that journals from inside the same transaction as the
+// history, so other processes can't slip anything in between. Todos the
+// change adds are picked up on their own, and changes that turn out not to
+// change anything are left out of the journal
+func (t *Todos) journal(name string, ids []int, change func(db *DB) error) error {
+	db := *t.db
+	db.operation = &pendingOperation{name: name, ids: ids}
+	return change(&db)
+}
+
+// Like "complete #4 Write the report", or "purge 12 todos" for bulk changes
+func describe(name string, before, after snapshot) string {
+	rows := after.Todos
+	if len(before.Todos) > len(rows) {
+		rows = before.Todos
+	}
+	switch len(rows) {
+	case 0:
+		return name
+	case 1, 2:
+		var todos []string
+		for _, row := range rows {
+			todos = append(todos, fmt.Sprintf("#%d %s", row.ID, row.Task))
+		}
+		return name + " " + strings.Join(todos, ", ")
+	}
+	return fmt.Sprintf("%s %d todos", name, len(rows))
+}
+
+func (t *Todos) Undo(n int) ([]operation, error) {
+	return t.db.UndoOperations(n)
+}
+
+func (t *Todos) Redo(n int) ([]operation, error) {
+	return t.db.RedoOperations(n)
+}
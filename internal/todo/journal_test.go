@@ -0,0 +1,224 @@
+package todo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUndoRedo(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+
+	if _, err := todos.Undo(1); !errors.Is(err, ErrNothingToUndo) {
+		t.Fatalf("Expected ErrNothingToUndo on an empty journal, got %v", err)
+	}
+
+	if err := todos.AddWithOptions("Water the plants", AddOptions{Recurrence: "daily", Project: "home"}); err != nil {
+		t.Fatalf("AddWithOptions failed: %v", err)
+	}
+	if err := todos.Add("Write the report"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	all, _ := db.GetAllTodos()
+	if len(all) != 2 {
+		t.Fatalf("Expected 2 todos, got %d", len(all))
+	}
+	plants, report := all[0].ID, all[1].ID
+	if all[0].Task != "Water the plants" {
+		plants, report = report, plants
+	}
+
+	// Completing a recurring todo adds the next one, undo takes both back
+	if _, err := todos.Complete(plants); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if err := todos.Edit(report, "Write the quarterly report"); err != nil {
+		t.Fatalf("Edit failed: %v", err)
+	}
+	if err := todos.Delete(report); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	ops, err := todos.Undo(3)
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if len(ops) != 3 || ops[0].Name != "delete" || ops[1].Name != "edit" || ops[2].Name != "complete" {
+		t.Fatalf("Expected delete, edit and complete undone in that order, got %v", ops)
+	}
+
+	all, _ = db.GetAllTodos()
+	if len(all) != 2 {
+		t.Fatalf("Expected the next occurrence gone after undo, got %v", all)
+	}
+	item, err := db.GetTodo(plants)
+	if err != nil {
+		t.Fatalf("GetTodo failed: %v", err)
+	}
+	if item.Done || item.Status != StatusTodo || !item.CompletedAt.IsZero() {
+		t.Errorf("Expected todo %d pending again, got %+v", plants, item)
+	}
+	if item, _ := db.GetTodo(report); item.Task != "Write the report" {
+		t.Errorf("Expected the edit undone, got %q", item.Task)
+	}
+	transitions, _ := db.GetTransitions(plants)
+	if len(transitions) != 2 || transitions[1].From != StatusDone || transitions[1].To != StatusTodo {
+		t.Errorf("Expected the undo recorded as a transition back to todo, got %v", transitions)
+	}
+
+	// Redo walks forward again, oldest first
+	ops, err = todos.Redo(2)
+	if err != nil {
+		t.Fatalf("Redo failed: %v", err)
+	}
+	if len(ops) != 2 || ops[0].Name != "complete" || ops[1].Name != "edit" {
+		t.Fatalf("Expected complete and edit redone, got %v", ops)
+	}
+	all, _ = db.GetAllTodos()
+	if len(all) != 3 {
+		t.Errorf("Expected the next occurrence back after redo, got %d todos", len(all))
+	}
+	if item, _ := db.GetTodo(report); item.Task != "Write the quarterly report" {
+		t.Errorf("Expected the edit redone, got %q", item.Task)
+	}
+
+	// A new change drops what is left to redo
+	if err := todos.SetProject(report, "work"); err != nil {
+		t.Fatalf("SetProject failed: %v", err)
+	}
+	if _, err := todos.Redo(1); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("Expected ErrNothingToRedo after a new change, got %v", err)
+	}
+
+	// Changes that change nothing are not journaled
+	if err := todos.SetProject(report, "work"); err != nil {
+		t.Fatalf("SetProject failed: %v", err)
+	}
+	ops, err = todos.Undo(1)
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if ops[0].Name != "assign" {
+		t.Fatalf("Expected the first assign undone, got %s", ops[0].Name)
+	}
+	if item, _ := db.GetTodo(report); item.Project != "" {
+		t.Errorf("Expected the project cleared, got %q", item.Project)
+	}
+
+	// Undoing everything leaves an empty list
+	if _, err := todos.Undo(10); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	all, _ = db.GetAllTodos()
+	if len(all) != 0 {
+		t.Errorf("Expected no todos after undoing every add, got %v", all)
+	}
+}
+
+func TestUndoDependenciesAndPurge(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+
+	blocker := addTestTask(t, db, "Blocker")
+	blocked := addTestTask(t, db, "Blocked")
+
+	if err := todos.Block(blocked, blocker); err != nil {
+		t.Fatalf("Block failed: %v", err)
+	}
+	if err := todos.Delete(blocker); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := todos.Purge(0); err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	if _, err := db.GetTodo(blocker); err == nil {
+		t.Fatal("Expected the blocker purged")
+	}
+
+	// Undoing the purge brings the row and its dependency back, in the trash
+	ops, err := todos.Undo(1)
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if ops[0].Summary != "purge #1 Blocker" {
+		t.Errorf("Unexpected summary %q", ops[0].Summary)
+	}
+	trashed, _ := db.GetTrashedTodos()
+	if len(trashed) != 1 || trashed[0].ID != blocker {
+		t.Fatalf("Expected the blocker back in the trash, got %v", trashed)
+	}
+
+	if _, err := todos.Undo(1); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	item, err := db.GetTodo(blocked)
+	if err != nil {
+		t.Fatalf("GetTodo failed: %v", err)
+	}
+	if !item.Blocked {
+		t.Error("Expected the restored blocker to block again")
+	}
+
+	if _, err := todos.Undo(1); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if item, _ := db.GetTodo(blocked); item.Blocked {
+		t.Error("Expected the dependency gone after undoing the block")
+	}
+}
+
+func TestUndoStart(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+	id := addTestTask(t, db, "Review the PR")
+
+	if err := todos.Start(id); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	ops, err := todos.Undo(1)
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if len(ops) != 1 || ops[0].Name != "start" {
+		t.Fatalf("Expected the start undone, got %v", ops)
+	}
+	if item, _ := db.GetTodo(id); item.Status != StatusTodo {
+		t.Errorf("Expected todo %d back in todo, got %s", id, item.Status)
+	}
+	if _, ok, _ := db.RunningTimer(); ok {
+		t.Error("Expected the undo to take back the timer")
+	}
+
+	// Redo starts the same session again
+	if _, err := todos.Redo(1); err != nil {
+		t.Fatalf("Redo failed: %v", err)
+	}
+	if s, ok, _ := db.RunningTimer(); !ok || s.TodoID != id {
+		t.Errorf("Expected the timer running on %d after the redo, got %+v", id, s)
+	}
+
+	// Already in progress, undoing a second start only takes back its timer
+	if _, _, err := todos.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if err := todos.Start(id); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := todos.Undo(1); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if item, _ := db.GetTodo(id); item.Status != StatusInProgress {
+		t.Errorf("Expected todo %d still in progress, got %s", id, item.Status)
+	}
+	if _, ok, _ := db.RunningTimer(); ok {
+		t.Error("Expected the second undo to take back its timer")
+	}
+	var sessions int
+	db.QueryRow(`SELECT COUNT(*) FROM sessions WHERE todo_id = ?`, id).Scan(&sessions)
+	if sessions != 1 {
+		t.Errorf("Expected the stopped session kept, got %d sessions", sessions)
+	}
+}
@@ -0,0 +1,221 @@
+package todo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported recurrence rules:
+//
+//	daily            every day
+//	weekdays         monday to friday
+//	weekly           every 7 days
+//	weekly:mon,thu   on the given days of the week
+//	monthly          same day every month, the last day in shorter months
+//	monthly:31       on the given day of the month
+//	every:3d         every N days (or weeks with a w suffix) after the due date
+//	after:2d         N days (or weeks) after the todo was completed
+type Recurrence struct {
+	Kind string
+	Days []time.Weekday
+	// Days between occurrences, or the day of the month for monthly
+	Interval int
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func ParseRecurrence(rule string) (Recurrence, error) {
+	kind, arg, _ := strings.Cut(strings.ToLower(strings.TrimSpace(rule)), ":")
+
+	switch kind {
+	case "monthly":
+		if arg == "" {
+			return Recurrence{Kind: kind}, nil
+		}
+		day, err := strconv.Atoi(arg)
+		if err != nil || day < 1 || day > 31 {
+			return Recurrence{}, fmt.Errorf("invalid day of the month in recurrence %q", rule)
+		}
+		return Recurrence{Kind: kind, Interval: day}, nil
+
+	case "daily", "weekdays":
+		if arg != "" {
+			return Recurrence{}, fmt.Errorf("recurrence %q takes no argument", kind)
+		}
+		return Recurrence{Kind: kind}, nil
+
+	case "weekly":
+		r := Recurrence{Kind: kind}
+		if arg == "" {
+			return r, nil
+		}
+		for _, name := range strings.Split(arg, ",") {
+			name = strings.TrimSpace(name)
+			if len(name) > 3 {
+				name = name[:3]
+			}
+			day, ok := weekdayNames[name]
+			if !ok {
+				return Recurrence{}, fmt.Errorf("unknown weekday %q in recurrence %q", name, rule)
+			}
+			r.Days = append(r.Days, day)
+		}
+		return r, nil
+
+	case "every", "after":
+		days, err := parseDays(arg)
+		if err != nil {
+			return Recurrence{}, fmt.Errorf("invalid interval in recurrence %q: %w", rule, err)
+		}
+		return Recurrence{Kind: kind, Interval: days}, nil
+	}
+
+	return Recurrence{}, fmt.Errorf("unknown recurrence %q", rule)
+}
+
+// Parses intervals like 3, 3d or 2w into a number of days
+func parseDays(s string) (int, error) {
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(s, "d"):
+		s = strings.TrimSuffix(s, "d")
+	case strings.HasSuffix(s, "w"):
+		s = strings.TrimSuffix(s, "w")
+		multiplier = 7
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("interval must be positive, got %d", n)
+	}
+	return n * multiplier, nil
+}
+
+func (r Recurrence) String() string {
+	switch r.Kind {
+	case "weekly":
+		if len(r.Days) == 0 {
+			return r.Kind
+		}
+		var names []string
+		for _, day := range r.Days {
+			names = append(names, strings.ToLower(day.String()[:3]))
+		}
+		return r.Kind + ":" + strings.Join(names, ",")
+	case "every", "after":
+		return fmt.Sprintf("%s:%dd", r.Kind, r.Interval)
+	case "monthly":
+		if r.Interval > 0 {
+			return fmt.Sprintf("%s:%d", r.Kind, r.Interval)
+		}
+	}
+	return r.Kind
+}
+
+func (r Recurrence) matches(day time.Time) bool {
+	switch r.Kind {
+	case "weekdays":
+		return day.Weekday() != time.Saturday && day.Weekday() != time.Sunday
+	case "weekly":
+		for _, d := range r.Days {
+			if day.Weekday() == d {
+				return true
+			}
+		}
+		return len(r.Days) == 0
+	case "monthly":
+		if r.Interval == 0 {
+			return true
+		}
+		last := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+		return day.Day() == min(r.Interval, last)
+	}
+	return true
+}
+
+// First due date for a newly added recurring todo: today, or the first
+// matching day from today on for rules bound to days of the week or a day
+// of the month
+func (r Recurrence) First(now time.Time) time.Time {
+	day := startOfDay(now)
+	if r.Kind == "weekly" && len(r.Days) == 0 {
+		return day
+	}
+	for !r.matches(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// Pins a monthly rule to the day of the month of due, so occurrences that
+// get moved up to the end of a shorter month go back to that day after
+func (r Recurrence) Anchor(due time.Time) Recurrence {
+	if r.Kind == "monthly" && r.Interval == 0 && !due.IsZero() {
+		r.Interval = due.Day()
+	}
+	return r
+}
+
+// Next due date after an occurrence due on `due` was completed at `completed`.
+// Occurrences missed while the todo was left open are skipped, so the next one
+// always lands after the day it was completed
+func (r Recurrence) Next(due, completed time.Time) time.Time {
+	completedDay := startOfDay(completed)
+	if r.Kind == "after" {
+		return completedDay.AddDate(0, 0, r.Interval)
+	}
+
+	r = r.Anchor(due)
+	next := completedDay
+	if !due.IsZero() {
+		next = startOfDay(due)
+	}
+	next = r.step(next)
+	for !next.After(completedDay) {
+		next = r.step(next)
+	}
+	return next
+}
+
+func (r Recurrence) step(day time.Time) time.Time {
+	switch r.Kind {
+	case "monthly":
+		// AddDate would roll the 31st over into the month after
+		first := time.Date(day.Year(), day.Month()+1, 1, day.Hour(), day.Minute(), day.Second(), day.Nanosecond(), day.Location())
+		last := first.AddDate(0, 1, -1).Day()
+		anchor := r.Interval
+		if anchor == 0 {
+			anchor = day.Day()
+		}
+		return first.AddDate(0, 0, min(anchor, last)-1)
+	case "every":
+		return day.AddDate(0, 0, r.Interval)
+	case "weekly":
+		if len(r.Days) == 0 {
+			return day.AddDate(0, 0, 7)
+		}
+	}
+
+	day = day.AddDate(0, 0, 1)
+	for !r.matches(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
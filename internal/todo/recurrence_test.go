@@ -0,0 +1,179 @@
+package todo
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	valid := map[string]string{
+		"daily":              "daily",
+		"Weekdays":           "weekdays",
+		"weekly":             "weekly",
+		"weekly:monday, thu": "weekly:mon,thu",
+		"monthly":            "monthly",
+		"monthly:31":         "monthly:31",
+		"every:3":            "every:3d",
+		"every:2w":           "every:14d",
+		"after:5d":           "after:5d",
+	}
+	for rule, want := range valid {
+		r, err := ParseRecurrence(rule)
+		if err != nil {
+			t.Errorf("ParseRecurrence(%q) failed: %v", rule, err)
+			continue
+		}
+		if r.String() != want {
+			t.Errorf("ParseRecurrence(%q).String() = %q, want %q", rule, r.String(), want)
+		}
+	}
+
+	for _, rule := range []string{"", "hourly", "daily:2", "weekly:funday", "every:0", "after:x", "monthly:0", "monthly:32", "monthly:last"} {
+		if _, err := ParseRecurrence(rule); err == nil {
+			t.Errorf("ParseRecurrence(%q) succeeded unexpectedly", rule)
+		}
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	day := func(d int) time.Time {
+		// September 2024, the 16th is a Monday
+		return time.Date(2024, 9, d, 0, 0, 0, 0, time.UTC)
+	}
+	at := func(d, hour int) time.Time {
+		return day(d).Add(time.Duration(hour) * time.Hour)
+	}
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		rule      string
+		due       time.Time
+		completed time.Time
+		want      time.Time
+	}{
+		{"daily", day(16), at(16, 10), day(17)},
+		{"daily", day(16), at(18, 10), day(19)},   // missed days are skipped
+		{"weekdays", day(20), at(20, 9), day(23)}, // friday -> monday
+		{"weekly", day(16), at(16, 9), day(23)},
+		{"weekly:mon,thu", day(16), at(16, 9), day(19)},
+		{"weekly:mon,thu", day(19), at(19, 9), day(23)},
+		{"monthly", day(16), at(16, 9), time.Date(2024, 10, 16, 0, 0, 0, 0, time.UTC)},
+		{"monthly", date(2024, 1, 31), date(2024, 1, 31), date(2024, 2, 29)},    // end of a shorter month
+		{"monthly:31", date(2024, 2, 29), date(2024, 2, 29), date(2024, 3, 31)}, // back on the 31st
+		{"monthly:31", date(2024, 3, 31), date(2024, 3, 31), date(2024, 4, 30)},
+		{"monthly", date(2024, 2, 29), date(2024, 2, 29), date(2024, 3, 29)},    // leap day
+		{"monthly:29", date(2025, 1, 29), date(2025, 1, 29), date(2025, 2, 28)}, // no leap day
+		{"monthly:29", date(2025, 2, 28), date(2025, 2, 28), date(2025, 3, 29)},
+		{"monthly:31", date(2024, 1, 31), date(2024, 3, 5), date(2024, 3, 31)}, // missed February
+		{"every:3d", day(16), at(16, 9), day(19)},
+		{"after:2d", day(16), at(18, 22), day(20)},
+		{"daily", day(18), at(16, 9), day(19)}, // completed ahead of time
+	}
+
+	for _, tt := range tests {
+		r, err := ParseRecurrence(tt.rule)
+		if err != nil {
+			t.Fatalf("ParseRecurrence(%q) failed: %v", tt.rule, err)
+		}
+		got := r.Next(tt.due, tt.completed)
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: Next(%v, %v) = %v, want %v", tt.rule, tt.due, tt.completed, got, tt.want)
+		}
+	}
+}
+
+func TestCompleteRecurringTodo(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	rule, _ := ParseRecurrence("daily")
+	today := startOfDay(time.Now())
+	if err := db.AddRecurringTodo("Triage inbox", rule, today); err != nil {
+		t.Fatalf("AddRecurringTodo failed: %v", err)
+	}
+
+	pending, err := db.GetPendingTodos()
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("Expected 1 pending todo, got %d (err: %v)", len(pending), err)
+	}
+	if pending[0].Recurrence != "daily" {
+		t.Errorf("Expected recurrence 'daily', got %q", pending[0].Recurrence)
+	}
+
+	if err := db.CompleteTodo(pending[0].ID); err != nil {
+		t.Fatalf("CompleteTodo failed: %v", err)
+	}
+
+	next, err := db.GetPendingTodos()
+	if err != nil || len(next) != 1 {
+		t.Fatalf("Expected the next occurrence to be pending, got %d (err: %v)", len(next), err)
+	}
+	if next[0].ID == pending[0].ID || next[0].Task != "Triage inbox" {
+		t.Errorf("Unexpected next occurrence: %+v", next[0])
+	}
+	if !next[0].DueAt.Equal(today.AddDate(0, 0, 1)) {
+		t.Errorf("Expected next occurrence due %v, got %v", today.AddDate(0, 0, 1), next[0].DueAt)
+	}
+
+	// Completing it again must not spawn a second copy
+	if err := db.CompleteTodo(pending[0].ID); err != nil {
+		t.Fatalf("CompleteTodo on a done todo failed: %v", err)
+	}
+	all, _ := db.GetAllTodos()
+	if len(all) != 2 {
+		t.Errorf("Expected 2 todos after completing twice, got %d", len(all))
+	}
+}
+
+func TestMonthlyKeepsItsDay(t *testing.T) {
+	r, _ := ParseRecurrence("monthly")
+	due := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	r = r.Anchor(due)
+	if r.String() != "monthly:31" {
+		t.Fatalf("Expected the rule pinned to the 31st, got %q", r.String())
+	}
+
+	var got []string
+	for i := 0; i < 5; i++ {
+		due = r.Next(due, due)
+		got = append(got, due.Format("2006-01-02"))
+	}
+	want := "2024-02-29 2024-03-31 2024-04-30 2024-05-31 2024-06-30"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Expected %s, got %s", want, strings.Join(got, " "))
+	}
+}
+
+func TestRecurrenceFirst(t *testing.T) {
+	date := func(m time.Month, d, hour int) time.Time {
+		return time.Date(2024, m, d, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		rule string
+		now  time.Time
+		want time.Time
+	}{
+		{"monthly", date(10, 3, 9), date(10, 3, 0)},
+		{"monthly:15", date(10, 3, 9), date(10, 15, 0)},  // later this month
+		{"monthly:15", date(10, 15, 9), date(10, 15, 0)}, // today
+		{"monthly:15", date(10, 16, 9), date(11, 15, 0)}, // passed, next month
+		{"monthly:31", date(9, 3, 9), date(9, 30, 0)},    // shorter month
+		{"monthly:31", date(2, 10, 9), date(2, 29, 0)},   // leap February
+		{"monthly:31", date(4, 30, 9), date(4, 30, 0)},   // last day today
+		{"monthly:30", date(1, 31, 9), date(2, 29, 0)},   // passed, clamped next month
+		{"weekly:fri", date(9, 16, 9), date(9, 20, 0)},
+	}
+	for _, tt := range tests {
+		r, err := ParseRecurrence(tt.rule)
+		if err != nil {
+			t.Fatalf("ParseRecurrence(%q) failed: %v", tt.rule, err)
+		}
+		if got := r.First(tt.now); !got.Equal(tt.want) {
+			t.Errorf("%s from %s: expected %s, got %s", tt.rule, tt.now.Format("2006-01-02"), tt.want.Format("2006-01-02"), got.Format("2006-01-02"))
+		}
+	}
+}
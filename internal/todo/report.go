@@ -0,0 +1,252 @@
+package todo
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report periods
+const (
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+type reportProject struct {
+	Name  string
+	Tasks []reportTask
+}
+
+type reportTask struct {
+	Task string
+	// All the time tracked on it, not just in the period
+	Spent time.Duration
+}
+
+// Everything completed on one day, split up by project
+type reportDay struct {
+	Date     time.Time
+	Count    int
+	Projects []reportProject
+}
+
+type projectCount struct {
+	Name  string
+	Count int
+}
+
+// What got done over a longer period, for weekly status emails and reviews
+type Report struct {
+	Since      time.Time
+	Until      time.Time
+	Total      int
+	Tracked    time.Duration
+	Days       []reportDay
+	Projects   []projectCount
+	Highlights []string
+}
+
+// Start and end of the week (starting Monday) or month now falls in. With
+// previous set it is the one before that instead
+func PeriodBounds(period string, now time.Time, previous bool) (time.Time, time.Time, error) {
+	day := startOfDay(now)
+	switch period {
+	case PeriodWeek:
+		// Weekday counts from Sunday, weeks here start on Monday
+		since := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		if previous {
+			since = since.AddDate(0, 0, -7)
+		}
+		return since, since.AddDate(0, 0, 7), nil
+	case PeriodMonth:
+		since := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+		if previous {
+			since = since.AddDate(0, -1, 0)
+		}
+		return since, since.AddDate(0, 1, 0), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("unknown period %q, expected %s or %s", period, PeriodWeek, PeriodMonth)
+}
+
+// Builds the report of the todos completed in (since, until]
+func (t *Todos) Report(since, until time.Time) (Report, error) {
+	loc := t.db.location
+	report := Report{Since: since.In(loc), Until: until.In(loc)}
+
+	completed, err := t.completedBetween(since, until)
+	if err != nil {
+		return report, err
+	}
+	sort.SliceStable(completed, func(i, j int) bool {
+		return completed[i].CompletedAt.Before(completed[j].CompletedAt)
+	})
+	report.Total = len(completed)
+
+	spent, err := t.db.TimeSpent(time.Time{}, time.Time{})
+	if err != nil {
+		return report, err
+	}
+	inPeriod, err := t.db.TimeSpent(since, until)
+	if err != nil {
+		return report, err
+	}
+	for _, d := range inPeriod {
+		report.Tracked += d
+	}
+
+	counts := map[string]int{}
+	for _, item := range completed {
+		project := item.Project
+		if project == "" {
+			project = noProject
+		}
+		counts[project]++
+
+		date := startOfDay(item.CompletedAt)
+		if n := len(report.Days); n == 0 || !report.Days[n-1].Date.Equal(date) {
+			report.Days = append(report.Days, reportDay{Date: date})
+		}
+		day := &report.Days[len(report.Days)-1]
+		day.Count++
+		day.addTask(project, reportTask{Task: item.Task, Spent: spent[item.ID]})
+	}
+
+	for name, count := range counts {
+		report.Projects = append(report.Projects, projectCount{name, count})
+	}
+	sort.Slice(report.Projects, func(i, j int) bool {
+		if report.Projects[i].Count != report.Projects[j].Count {
+			return report.Projects[i].Count > report.Projects[j].Count
+		}
+		return report.Projects[i].Name < report.Projects[j].Name
+	})
+
+	report.Highlights = report.highlights(completed)
+	return report, nil
+}
+
+func (d *reportDay) addTask(project string, task reportTask) {
+	for n := range d.Projects {
+		if d.Projects[n].Name == project {
+			d.Projects[n].Tasks = append(d.Projects[n].Tasks, task)
+			return
+		}
+	}
+	d.Projects = append(d.Projects, reportProject{Name: project, Tasks: []reportTask{task}})
+}
+
+// A few lines worth calling out: the busiest day, the main project and the
+// longest running todo that finally got done
+func (r Report) highlights(completed []item) []string {
+	if len(completed) == 0 {
+		return nil
+	}
+
+	var lines []string
+	busiest := r.Days[0]
+	for _, day := range r.Days[1:] {
+		if day.Count > busiest.Count {
+			busiest = day
+		}
+	}
+	lines = append(lines, fmt.Sprintf("Busiest day: %s with %d done", busiest.Date.Format("Monday "+dayFormat), busiest.Count))
+
+	if top := r.Projects[0]; top.Name != noProject {
+		lines = append(lines, fmt.Sprintf("Most active project: %s (%d of %d)", top.Name, top.Count, r.Total))
+	}
+
+	oldest := completed[0]
+	for _, item := range completed[1:] {
+		if item.CompletedAt.Sub(item.CreatedAt) > oldest.CompletedAt.Sub(oldest.CreatedAt) {
+			oldest = item
+		}
+	}
+	if age := oldest.CompletedAt.Sub(oldest.CreatedAt); age >= 24*time.Hour {
+		lines = append(lines, fmt.Sprintf("Longest running: %s, open for %d days", oldest.Task, int(age.Hours()/24)))
+	}
+	return lines
+}
+
+// Renders the report as plain text, markdown or HTML
+func (r Report) Render(format string) (string, error) {
+	// The window is (since, until] and until is usually the midnight after
+	// the last day, so show the day just before it
+	title := fmt.Sprintf("Done %s to %s", r.Since.Format(dayFormat), r.Until.Add(-time.Nanosecond).Format(dayFormat))
+	summary := fmt.Sprintf("%d completed", r.Total)
+	if r.Tracked > 0 {
+		summary += ", " + formatDuration(r.Tracked) + " tracked"
+	}
+	var counts []string
+	for _, p := range r.Projects {
+		counts = append(counts, fmt.Sprintf("%s %d", p.Name, p.Count))
+	}
+	if len(counts) > 0 {
+		summary += ", by project: " + strings.Join(counts, ", ")
+	}
+
+	var b strings.Builder
+	switch format {
+	case FormatText:
+		fmt.Fprintf(&b, "%s\n%s\n", title, summary)
+		if len(r.Highlights) > 0 {
+			b.WriteString("\nHighlights:\n")
+			for _, h := range r.Highlights {
+				fmt.Fprintf(&b, "* %s\n", h)
+			}
+		}
+		for _, day := range r.Days {
+			fmt.Fprintf(&b, "\n%s (%d):\n", day.Date.Format("Mon "+dayFormat), day.Count)
+			for _, p := range day.Projects {
+				fmt.Fprintf(&b, "  %s\n", p.Name)
+				for _, task := range p.Tasks {
+					fmt.Fprintf(&b, "    * %s%s\n", task.Task, noteSuffix(formatSpent(task.Spent), " (%s)"))
+				}
+			}
+		}
+
+	case FormatMarkdown:
+		fmt.Fprintf(&b, "# %s\n\n%s\n", title, summary)
+		if len(r.Highlights) > 0 {
+			b.WriteString("\n## Highlights\n\n")
+			for _, h := range r.Highlights {
+				fmt.Fprintf(&b, "- %s\n", h)
+			}
+		}
+		for _, day := range r.Days {
+			fmt.Fprintf(&b, "\n## %s (%d)\n", day.Date.Format("Monday "+dayFormat), day.Count)
+			for _, p := range day.Projects {
+				fmt.Fprintf(&b, "\n**%s**\n\n", p.Name)
+				for _, task := range p.Tasks {
+					fmt.Fprintf(&b, "- %s%s\n", task.Task, noteSuffix(formatSpent(task.Spent), " _(%s)_"))
+				}
+			}
+		}
+
+	case FormatHTML:
+		fmt.Fprintf(&b, "<h2>%s</h2>\n<p>%s</p>\n", html.EscapeString(title), html.EscapeString(summary))
+		if len(r.Highlights) > 0 {
+			b.WriteString("<h3>Highlights</h3>\n<ul>\n")
+			for _, h := range r.Highlights {
+				fmt.Fprintf(&b, "  <li>%s</li>\n", html.EscapeString(h))
+			}
+			b.WriteString("</ul>\n")
+		}
+		for _, day := range r.Days {
+			fmt.Fprintf(&b, "<h3>%s (%d)</h3>\n", day.Date.Format("Monday "+dayFormat), day.Count)
+			for _, p := range day.Projects {
+				fmt.Fprintf(&b, "<h4>%s</h4>\n<ul>\n", html.EscapeString(p.Name))
+				for _, task := range p.Tasks {
+					fmt.Fprintf(&b, "  <li>%s%s</li>\n", html.EscapeString(task.Task), noteSuffix(formatSpent(task.Spent), " <em>(%s)</em>"))
+				}
+				b.WriteString("</ul>\n")
+			}
+		}
+
+	default:
+		return "", fmt.Errorf("unknown format %q, expected %s, %s or %s", format, FormatText, FormatMarkdown, FormatHTML)
+	}
+
+	return b.String(), nil
+}
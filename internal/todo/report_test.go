@@ -0,0 +1,116 @@
+package todo
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Marks a todo done at a fixed time, for reports over past periods
+func completeAt(t *testing.T, db *DB, id int, at time.Time) {
+	t.Helper()
+	_, err := db.Exec(`UPDATE todos SET done = 1, status = 'done', completed_at = ? WHERE id = ?`, at.UTC(), id)
+	if err != nil {
+		t.Fatalf("Completing %d failed: %v", id, err)
+	}
+}
+
+func TestPeriodBounds(t *testing.T) {
+	// Thursday
+	now := time.Date(2024, 9, 19, 15, 0, 0, 0, time.UTC)
+	day := func(m time.Month, d int) time.Time {
+		return time.Date(2024, m, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		period   string
+		previous bool
+		since    time.Time
+		until    time.Time
+	}{
+		{PeriodWeek, false, day(9, 16), day(9, 23)},
+		{PeriodWeek, true, day(9, 9), day(9, 16)},
+		{PeriodMonth, false, day(9, 1), day(10, 1)},
+		{PeriodMonth, true, day(8, 1), day(9, 1)},
+	}
+	for _, tt := range tests {
+		since, until, err := PeriodBounds(tt.period, now, tt.previous)
+		if err != nil || !since.Equal(tt.since) || !until.Equal(tt.until) {
+			t.Errorf("PeriodBounds(%s, %v) = %v, %v (err: %v), want %v, %v", tt.period, tt.previous, since, until, err, tt.since, tt.until)
+		}
+	}
+
+	// Sunday still belongs to the week that started on Monday
+	since, _, _ := PeriodBounds(PeriodWeek, time.Date(2024, 9, 22, 9, 0, 0, 0, time.UTC), false)
+	if !since.Equal(day(9, 16)) {
+		t.Errorf("Sunday: expected the week to start %v, got %v", day(9, 16), since)
+	}
+
+	if _, _, err := PeriodBounds("fortnight", now, false); err == nil {
+		t.Error("Expected an error for an unknown period")
+	}
+}
+
+func TestReport(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	db.SetLocation(time.UTC)
+	todos := NewTodos(db)
+
+	at := func(d, h int) time.Time {
+		return time.Date(2024, 9, d, h, 0, 0, 0, time.UTC)
+	}
+	add := func(task, project string, done time.Time) {
+		t.Helper()
+		id := addTestTask(t, db, task)
+		if project != "" {
+			if err := db.SetProject(id, project); err != nil {
+				t.Fatalf("SetProject failed: %v", err)
+			}
+		}
+		if !done.IsZero() {
+			completeAt(t, db, id, done)
+		}
+	}
+	add("Fix <login>", "website", at(16, 10))
+	add("Update footer", "website", at(16, 15))
+	add("Reply to vendor", "", at(16, 11))
+	add("Release notes", "website", at(18, 9))
+	add("Last week", "website", at(13, 9))
+	add("Still open", "website", time.Time{})
+
+	report, err := todos.Report(at(16, 0), at(23, 0))
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+
+	if report.Total != 4 || len(report.Days) != 2 {
+		t.Fatalf("Expected 4 todos over 2 days, got %d over %d", report.Total, len(report.Days))
+	}
+	monday := report.Days[0]
+	if monday.Count != 3 || len(monday.Projects) != 2 || monday.Projects[0].Name != "website" || len(monday.Projects[0].Tasks) != 2 {
+		t.Errorf("Unexpected grouping for Monday: %+v", monday)
+	}
+	if report.Projects[0] != (projectCount{"website", 3}) || report.Projects[1] != (projectCount{noProject, 1}) {
+		t.Errorf("Unexpected project counts: %+v", report.Projects)
+	}
+
+	for _, format := range []string{FormatText, FormatMarkdown, FormatHTML} {
+		out, err := report.Render(format)
+		if err != nil {
+			t.Fatalf("Render(%s) failed: %v", format, err)
+		}
+		for _, want := range []string{"2024-09-16 to 2024-09-22", "Busiest day: Monday 2024-09-16 with 3 done", "Most active project: website (3 of 4)"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Render(%s): expected %q in\n%s", format, want, out)
+			}
+		}
+		if format == FormatHTML && !strings.Contains(out, "Fix &lt;login&gt;") {
+			t.Errorf("Expected escaped tasks in HTML, got\n%s", out)
+		}
+	}
+
+	if _, err := report.Render(FormatSlack); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
@@ -0,0 +1,184 @@
+package todo
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// Output formats for reports
+const (
+	FormatText     = "text"
+	FormatSlack    = "slack"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+type standupEntry struct {
+	Task string
+	// Extra context shown after the task, like why it is blocked
+	Note string
+}
+
+// Everything said at a standup: what got done in the period, what is on for
+// today and what is stuck
+type StandupReport struct {
+	Date      time.Time
+	Since     time.Time
+	Until     time.Time
+	Yesterday []standupEntry
+	Today     []standupEntry
+	Blockers  []standupEntry
+	// Time tracked on any todo in the period
+	Tracked time.Duration
+}
+
+// Builds the standup for the period (since, until], a zero until leaves the end open
+func (t *Todos) Standup(since, until, now time.Time) (StandupReport, error) {
+	loc := t.db.location
+	report := StandupReport{Date: now.In(loc), Since: since.In(loc), Until: until}
+	if !until.IsZero() {
+		report.Until = until.In(loc)
+	}
+
+	completed, err := t.db.GetCompletedTodosBetween(since, until)
+	if err != nil {
+		return report, err
+	}
+	spent, err := t.db.TimeSpent(since, until)
+	if err != nil {
+		return report, err
+	}
+	for _, d := range spent {
+		report.Tracked += d
+	}
+	for _, item := range completed {
+		report.Yesterday = append(report.Yesterday, standupEntry{Task: item.Task, Note: formatSpent(spent[item.ID])})
+	}
+
+	pending, err := t.db.GetPendingTodos()
+	if err != nil {
+		return report, err
+	}
+
+	// Work already started goes first, recurring todos only once they are due
+	endOfDay := startOfDay(now.In(loc)).AddDate(0, 0, 1)
+	var started, rest []standupEntry
+	for _, item := range pending {
+		switch {
+		case item.stuck():
+			note, err := t.blockedReason(item)
+			if err != nil {
+				return report, err
+			}
+			report.Blockers = append(report.Blockers, standupEntry{Task: item.Task, Note: note})
+		case item.Status == StatusInProgress:
+			note := "in progress"
+			if spent[item.ID] > 0 {
+				note += ", " + formatDuration(spent[item.ID]) + " so far"
+			}
+			started = append(started, standupEntry{Task: item.Task, Note: note})
+		case item.DueAt.IsZero() || item.DueAt.Before(endOfDay):
+			rest = append(rest, standupEntry{Task: item.Task})
+		}
+	}
+	report.Today = append(started, rest...)
+
+	return report, nil
+}
+
+// Why a todo cannot move on: its state, or the dependencies still open
+func (t *Todos) blockedReason(i item) (string, error) {
+	if !i.Blocked {
+		return i.Status, nil
+	}
+
+	blockers, err := t.db.GetBlockers(i.ID)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, blocker := range blockers {
+		if blocker.pending() {
+			names = append(names, blocker.Task)
+		}
+	}
+	return "waiting on " + strings.Join(names, ", "), nil
+}
+
+// Renders the report as plain text, Slack flavored markdown or HTML
+func (r StandupReport) Render(format string) (string, error) {
+	sections := []struct {
+		title   string
+		entries []standupEntry
+	}{
+		{"Yesterday", r.Yesterday},
+		{"Today", r.Today},
+		{"Blockers", r.Blockers},
+	}
+
+	title := fmt.Sprintf("Standup %s (since %s)", r.Date.Format(dayFormat), r.Since.Format(dayFormat))
+	if !r.Until.IsZero() {
+		// The window is (since, until] and until is usually the midnight
+		// after the last day, so show the day just before it
+		title = fmt.Sprintf("Standup %s to %s", r.Since.Format(dayFormat), r.Until.Add(-time.Nanosecond).Format(dayFormat))
+	}
+	if r.Tracked > 0 {
+		title += ", " + formatDuration(r.Tracked) + " tracked"
+	}
+
+	var b strings.Builder
+	switch format {
+	case FormatText:
+		fmt.Fprintf(&b, "%s\n", title)
+		for _, s := range sections {
+			fmt.Fprintf(&b, "\n%s:\n", s.title)
+			if len(s.entries) == 0 {
+				b.WriteString("Nothing to report.\n")
+			}
+			for _, e := range s.entries {
+				fmt.Fprintf(&b, "* %s%s\n", e.Task, noteSuffix(e.Note, " (%s)"))
+			}
+		}
+
+	case FormatSlack:
+		fmt.Fprintf(&b, "*%s*\n", title)
+		for _, s := range sections {
+			fmt.Fprintf(&b, "\n*%s*\n", s.title)
+			if len(s.entries) == 0 {
+				b.WriteString("_Nothing to report._\n")
+			}
+			for _, e := range s.entries {
+				fmt.Fprintf(&b, "• %s%s\n", e.Task, noteSuffix(e.Note, " _(%s)_"))
+			}
+		}
+
+	case FormatHTML:
+		fmt.Fprintf(&b, "<h2>%s</h2>\n", html.EscapeString(title))
+		for _, s := range sections {
+			fmt.Fprintf(&b, "<h3>%s</h3>\n", s.title)
+			if len(s.entries) == 0 {
+				b.WriteString("<p><em>Nothing to report.</em></p>\n")
+				continue
+			}
+			b.WriteString("<ul>\n")
+			for _, e := range s.entries {
+				fmt.Fprintf(&b, "  <li>%s%s</li>\n", html.EscapeString(e.Task), noteSuffix(html.EscapeString(e.Note), " <em>(%s)</em>"))
+			}
+			b.WriteString("</ul>\n")
+		}
+
+	default:
+		return "", fmt.Errorf("unknown format %q, expected %s, %s or %s", format, FormatText, FormatSlack, FormatHTML)
+	}
+
+	return b.String(), nil
+}
+
+func noteSuffix(note, layout string) string {
+	if note == "" {
+		return ""
+	}
+	return fmt.Sprintf(layout, note)
+}
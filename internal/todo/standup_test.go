@@ -0,0 +1,85 @@
+package todo
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStandupReport(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+
+	idShipped := addTestTask(t, db, "Ship <release>")
+	idReview := addTestTask(t, db, "Review PR")
+	idBlocked := addTestTask(t, db, "Migrate users")
+	idWaiting := addTestTask(t, db, "Vendor contract")
+	addTestTask(t, db, "Write tests")
+
+	since := time.Now().Add(-time.Hour)
+	if err := db.CompleteTodo(idShipped); err != nil {
+		t.Fatalf("CompleteTodo failed: %v", err)
+	}
+	if _, err := todos.Move(idReview, StatusInProgress); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	if _, err := todos.Move(idWaiting, StatusWaiting); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	if err := todos.Block(idBlocked, idReview); err != nil {
+		t.Fatalf("Block failed: %v", err)
+	}
+
+	report, err := todos.Standup(since, time.Time{}, time.Now())
+	if err != nil {
+		t.Fatalf("Standup failed: %v", err)
+	}
+
+	if len(report.Yesterday) != 1 || report.Yesterday[0].Task != "Ship <release>" {
+		t.Errorf("Unexpected yesterday section: %+v", report.Yesterday)
+	}
+	if len(report.Today) != 2 || report.Today[0].Task != "Review PR" || report.Today[1].Task != "Write tests" {
+		t.Errorf("Expected the in progress todo first in today, got %+v", report.Today)
+	}
+	if len(report.Blockers) != 2 {
+		t.Fatalf("Expected 2 blockers, got %+v", report.Blockers)
+	}
+	for _, e := range report.Blockers {
+		if e.Task == "Migrate users" && e.Note != "waiting on Review PR" {
+			t.Errorf("Expected the blocking todo in the note, got %q", e.Note)
+		}
+	}
+
+	t.Run("Text", func(t *testing.T) {
+		out, err := report.Render(FormatText)
+		if err != nil {
+			t.Fatalf("Render failed: %v", err)
+		}
+		for _, want := range []string{"Yesterday:", "Today:", "Blockers:", "* Review PR (in progress)", "* Vendor contract (waiting)"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Text report is missing %q:\n%s", want, out)
+			}
+		}
+	})
+
+	t.Run("Slack", func(t *testing.T) {
+		out, _ := report.Render(FormatSlack)
+		if !strings.Contains(out, "*Blockers*") || !strings.Contains(out, "• Migrate users _(waiting on Review PR)_") {
+			t.Errorf("Unexpected slack report:\n%s", out)
+		}
+	})
+
+	t.Run("HTML escapes tasks", func(t *testing.T) {
+		out, _ := report.Render(FormatHTML)
+		if !strings.Contains(out, "<li>Ship &lt;release&gt;</li>") {
+			t.Errorf("Expected escaped task in html report:\n%s", out)
+		}
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		if _, err := report.Render("pdf"); err == nil {
+			t.Error("Expected an error for an unknown format")
+		}
+	})
+}
@@ -0,0 +1,258 @@
+package todo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alexeyco/simpletable"
+)
+
+const (
+	oldestOpenCount = 5
+	barWidth        = 30
+)
+
+// Created and completed todos in one day or week
+type periodCount struct {
+	Start     time.Time
+	Created   int
+	Completed int
+}
+
+type Stats struct {
+	Days  []periodCount
+	Weeks []periodCount
+	// Time from created to completed, over every completed todo
+	LeadTimes  int
+	LeadAvg    time.Duration
+	LeadMedian time.Duration
+	// Workdays in a row with something completed, up to today
+	Streak     int
+	OldestOpen []item
+	// Completions per weekday, Sunday first like time.Weekday
+	Weekdays [7]int
+}
+
+// Works out the stats over the last days days and weeks weeks before now
+func (t *Todos) Stats(now time.Time, days, weeks int) (Stats, error) {
+	todos, err := t.allTodos()
+	if err != nil {
+		return Stats{}, err
+	}
+	return computeStats(todos, now.In(t.db.location), days, weeks, t.calendar), nil
+}
+
+func computeStats(todos []item, now time.Time, days, weeks int, cal Calendar) Stats {
+	var s Stats
+	today := startOfDay(now)
+
+	first := today.AddDate(0, 0, -(days - 1))
+	for i := 0; i < days; i++ {
+		s.Days = append(s.Days, periodCount{Start: first.AddDate(0, 0, i)})
+	}
+	thisWeek, _, _ := PeriodBounds(PeriodWeek, now, false)
+	firstWeek := thisWeek.AddDate(0, 0, -7*(weeks-1))
+	for i := 0; i < weeks; i++ {
+		s.Weeks = append(s.Weeks, periodCount{Start: firstWeek.AddDate(0, 0, 7*i)})
+	}
+
+	var leads []time.Duration
+	var oldest time.Time
+	completedOn := map[string]bool{}
+	for _, item := range todos {
+		// Days are counted with AddDate so DST changes don't shift the index
+		if n := daysBetween(first, startOfDay(item.CreatedAt)); n >= 0 && n < days {
+			s.Days[n].Created++
+		}
+		if n := daysBetween(firstWeek, startOfDay(item.CreatedAt)) / 7; n >= 0 && n < weeks && !item.CreatedAt.Before(firstWeek) {
+			s.Weeks[n].Created++
+		}
+
+		if item.pending() {
+			s.OldestOpen = append(s.OldestOpen, item)
+			continue
+		}
+		if !item.Done || item.CompletedAt.IsZero() {
+			continue
+		}
+
+		day := startOfDay(item.CompletedAt)
+		if n := daysBetween(first, day); n >= 0 && n < days {
+			s.Days[n].Completed++
+		}
+		if n := daysBetween(firstWeek, day) / 7; n >= 0 && n < weeks && !day.Before(firstWeek) {
+			s.Weeks[n].Completed++
+		}
+		completedOn[day.Format(dayFormat)] = true
+		if oldest.IsZero() || day.Before(oldest) {
+			oldest = day
+		}
+		s.Weekdays[day.Weekday()]++
+		leads = append(leads, item.CompletedAt.Sub(item.CreatedAt))
+	}
+
+	if len(leads) > 0 {
+		var total time.Duration
+		for _, d := range leads {
+			total += d
+		}
+		sort.Slice(leads, func(i, j int) bool { return leads[i] < leads[j] })
+		s.LeadTimes = len(leads)
+		s.LeadAvg = total / time.Duration(len(leads))
+		s.LeadMedian = leads[len(leads)/2]
+		if len(leads)%2 == 0 {
+			s.LeadMedian = (leads[len(leads)/2-1] + leads[len(leads)/2]) / 2
+		}
+	}
+
+	// Only workdays count, days off neither add to the streak nor break it.
+	// Without any workday configured every day but a holiday is one. Today
+	// only counts once something got done
+	workday := func(day time.Time) bool {
+		if len(cal.workdays) == 0 {
+			return !cal.holidays[day.Format(dayFormat)]
+		}
+		return cal.IsWorkday(day)
+	}
+	day := today
+	if !completedOn[day.Format(dayFormat)] {
+		day = day.AddDate(0, 0, -1)
+	}
+	for ; !oldest.IsZero() && !day.Before(oldest); day = day.AddDate(0, 0, -1) {
+		if !workday(day) {
+			continue
+		}
+		if !completedOn[day.Format(dayFormat)] {
+			break
+		}
+		s.Streak++
+	}
+
+	sort.SliceStable(s.OldestOpen, func(i, j int) bool {
+		return s.OldestOpen[i].CreatedAt.Before(s.OldestOpen[j].CreatedAt)
+	})
+	if len(s.OldestOpen) > oldestOpenCount {
+		s.OldestOpen = s.OldestOpen[:oldestOpenCount]
+	}
+	return s
+}
+
+// Calendar days from a to b, both at the start of a day
+func daysBetween(a, b time.Time) int {
+	// Rounding takes care of the 23 and 25 hour days around DST changes
+	return int((b.Sub(a) + 12*time.Hour) / (24 * time.Hour))
+}
+
+func (t *Todos) PrintStats(now time.Time, days, weeks int) error {
+	s, err := t.Stats(now, days, weeks)
+	if err != nil {
+		return err
+	}
+
+	var created, completed []int
+	for _, d := range s.Days {
+		created = append(created, d.Created)
+		completed = append(completed, d.Completed)
+	}
+	fmt.Printf("Last %d days\n", days)
+	fmt.Printf("  created    %s %s\n", blue(sparkline(created)), gray(fmt.Sprintf("%d", sum(created))))
+	fmt.Printf("  completed  %s %s\n\n", green(sparkline(completed)), gray(fmt.Sprintf("%d", sum(completed))))
+
+	most := 0
+	for _, w := range s.Weeks {
+		most = max(most, w.Created, w.Completed)
+	}
+	table := simpletable.New()
+	table.Header = &simpletable.Header{
+		Cells: []*simpletable.Cell{
+			{Align: simpletable.AlignCenter, Text: "Week of"},
+			{Align: simpletable.AlignCenter, Text: "Created"},
+			{Align: simpletable.AlignCenter, Text: "Completed"},
+			{Align: simpletable.AlignCenter, Text: ""},
+		},
+	}
+	for _, w := range s.Weeks {
+		table.Body.Cells = append(table.Body.Cells, []*simpletable.Cell{
+			{Text: w.Start.Format("Jan 02")},
+			{Align: simpletable.AlignRight, Text: fmt.Sprintf("%d", w.Created)},
+			{Align: simpletable.AlignRight, Text: fmt.Sprintf("%d", w.Completed)},
+			{Text: green(bar(w.Completed, most, barWidth))},
+		})
+	}
+	table.SetStyle(simpletable.StyleUnicode)
+	table.Println()
+
+	fmt.Println()
+	if s.LeadTimes > 0 {
+		fmt.Printf("Lead time:  %s average, %s median over %d todos\n", formatDuration(s.LeadAvg), formatDuration(s.LeadMedian), s.LeadTimes)
+	}
+	fmt.Printf("Streak:     %d days\n", s.Streak)
+
+	if len(s.OldestOpen) > 0 {
+		fmt.Println("\nOldest open:")
+		for _, item := range s.OldestOpen {
+			fmt.Printf("  %s %s %s\n", gray(fmt.Sprintf("%4d", item.ID)), item.Task, gray(formatDuration(now.Sub(item.CreatedAt))))
+		}
+	}
+
+	fmt.Println("\nBusiest weekdays:")
+	most = 0
+	for _, n := range s.Weekdays {
+		most = max(most, n)
+	}
+	for i := 1; i <= 7; i++ {
+		day := time.Weekday(i % 7)
+		n := s.Weekdays[day]
+		fmt.Printf("  %s %s %d\n", day.String()[:3], green(bar(n, most, barWidth)), n)
+	}
+	return nil
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// One block per value, scaled to the largest
+func sparkline(values []int) string {
+	most := 0
+	for _, v := range values {
+		most = max(most, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		if most == 0 {
+			b.WriteRune(sparks[0])
+			continue
+		}
+		b.WriteRune(sparks[v*(len(sparks)-1)/most])
+	}
+	return b.String()
+}
+
+// A bar up to width wide, anything above zero gets at least one block
+func bar(n, most, width int) string {
+	if n <= 0 || most <= 0 {
+		return ""
+	}
+	return strings.Repeat("█", max(1, n*width/most))
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// Rough durations like 3d 4h, 5h 12m or 40m
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+}
@@ -0,0 +1,95 @@
+package todo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeStats(t *testing.T) {
+	// Thursday afternoon
+	now := time.Date(2024, 9, 19, 15, 0, 0, 0, time.UTC)
+	at := func(d, h int) time.Time {
+		return time.Date(2024, 9, d, h, 0, 0, 0, time.UTC)
+	}
+	done := func(created, completed time.Time) item {
+		return item{Status: StatusDone, Done: true, CreatedAt: created, CompletedAt: completed}
+	}
+	todos := []item{
+		done(at(18, 9), at(19, 9)),  // 1 day, Thursday
+		done(at(17, 9), at(18, 11)), // 1 day 2 hours, Wednesday
+		done(at(16, 9), at(16, 10)), // 1 hour, Monday
+		done(at(12, 9), at(13, 9)),  // Friday before the weekend
+		{ID: 7, Status: StatusTodo, CreatedAt: at(2, 9)},
+		{ID: 8, Status: StatusInProgress, CreatedAt: at(10, 9)},
+		{ID: 9, Status: StatusCancelled, CreatedAt: at(1, 9)},
+	}
+	cal, _ := DefaultConfig().Calendar()
+	s := computeStats(todos, now, 7, 2, cal)
+
+	if len(s.Days) != 7 || !s.Days[6].Start.Equal(at(19, 0)) {
+		t.Fatalf("Expected 7 days ending today, got %+v", s.Days)
+	}
+	if s.Days[6].Completed != 1 || s.Days[5].Completed != 1 || s.Days[5].Created != 1 || s.Days[3].Completed != 1 {
+		t.Errorf("Unexpected daily counts: %+v", s.Days)
+	}
+	if s.Weeks[0].Start != at(9, 0) || s.Weeks[0].Completed != 1 || s.Weeks[1].Completed != 3 || s.Weeks[1].Created != 3 {
+		t.Errorf("Unexpected weekly counts: %+v", s.Weeks)
+	}
+
+	if s.LeadTimes != 4 {
+		t.Errorf("Expected 4 lead times, got %d", s.LeadTimes)
+	}
+	if s.LeadMedian != 24*time.Hour {
+		t.Errorf("Expected a median of 24h, got %v", s.LeadMedian)
+	}
+	if want := (24*time.Hour*2 + 26*time.Hour + time.Hour) / 4; s.LeadAvg != want {
+		t.Errorf("Expected an average of %v, got %v", want, s.LeadAvg)
+	}
+
+	// Thursday, Wednesday, nothing on Tuesday
+	if s.Streak != 2 {
+		t.Errorf("Expected a streak of 2, got %d", s.Streak)
+	}
+	if len(s.OldestOpen) != 2 || s.OldestOpen[0].ID != 7 {
+		t.Errorf("Expected the open todos oldest first without cancelled ones, got %+v", s.OldestOpen)
+	}
+	if s.Weekdays[time.Thursday] != 1 || s.Weekdays[time.Friday] != 1 || s.Weekdays[time.Sunday] != 0 {
+		t.Errorf("Unexpected weekday counts: %v", s.Weekdays)
+	}
+}
+
+func TestStreakSkipsDaysOff(t *testing.T) {
+	// Monday morning, nothing done yet today
+	now := time.Date(2024, 9, 16, 8, 0, 0, 0, time.UTC)
+	var todos []item
+	// Wednesday to Friday, plus some work on Sunday
+	for _, d := range []int{11, 12, 13, 15} {
+		day := time.Date(2024, 9, d, 17, 0, 0, 0, time.UTC)
+		todos = append(todos, item{Status: StatusDone, Done: true, CreatedAt: day, CompletedAt: day})
+	}
+	cal, _ := DefaultConfig().Calendar()
+	if s := computeStats(todos, now, 7, 1, cal); s.Streak != 3 {
+		t.Errorf("Expected the weekend neither to break nor to add to the streak, got %d", s.Streak)
+	}
+
+	// Without workdays every day counts, so the empty Saturday breaks it
+	cal, _ = Config{}.Calendar()
+	if s := computeStats(todos, now, 7, 1, cal); s.Streak != 1 {
+		t.Errorf("Expected a streak of 1 without workdays, got %d", s.Streak)
+	}
+	if s := computeStats(nil, now, 7, 1, cal); s.Streak != 0 {
+		t.Errorf("Expected no streak without completions, got %d", s.Streak)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := sparkline([]int{0, 1, 2, 4, 8}); got != "▁▁▂▄█" {
+		t.Errorf("Unexpected sparkline %q", got)
+	}
+	if got := sparkline([]int{0, 0}); got != "▁▁" {
+		t.Errorf("Expected a flat line for no data, got %q", got)
+	}
+	if got := bar(1, 100, 10); got != "█" {
+		t.Errorf("Expected small values to still show, got %q", got)
+	}
+}
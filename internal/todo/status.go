@@ -0,0 +1,175 @@
+package todo
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	StatusTodo       = "todo"
+	StatusInProgress = "in-progress"
+	StatusWaiting    = "waiting"
+	StatusBlocked    = "blocked"
+	StatusDone       = "done"
+	StatusCancelled  = "cancelled"
+)
+
+// Workflow states in board order
+var Statuses = []string{StatusTodo, StatusInProgress, StatusWaiting, StatusBlocked, StatusDone, StatusCancelled}
+
+func validStatus(status string) error {
+	for _, s := range Statuses {
+		if s == status {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown status %q, expected one of %s", status, strings.Join(Statuses, ", "))
+}
+
+type transition struct {
+	TodoID    int
+	From      string
+	To        string
+	ChangedAt time.Time
+}
+
+// Moves a todo to a new workflow state and records the transition.
+// done is kept in sync with the status, and moving a recurring todo to done
+// adds its next occurrence. Cancelling a recurring todo ends the series.
+// A timer running on a todo that gets done or cancelled is stopped
+func (db *DB) SetStatus(id int, status string) error {
+	if err := validStatus(status); err != nil {
+		return err
+	}
+
+	return db.tracked([]int{id}, func(tx *sql.Tx) error {
+		return db.setStatus(tx, id, status)
+	})
+}
+
+// SetStatus inside a transaction that is already tracked
+func (db *DB) setStatus(tx *sql.Tx, id int, status string) error {
+	var from, task, rule, project, estimate string
+	var due sql.NullTime
+	err := tx.QueryRow(`
+			SELECT status, task, recurrence, due_at, project, estimate FROM todos WHERE id = ? AND deleted_at IS NULL
+		`, id).Scan(&from, &task, &rule, &due, &project, &estimate)
+	if err == sql.ErrNoRows {
+		// Nothing to move
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if from == status {
+		return nil
+	}
+
+	now := utcNow()
+	if status == StatusDone {
+		_, err = tx.Exec(`
+				UPDATE todos
+				SET status = ?, done = 1, completed_at = ?
+				WHERE id = ?
+			`, status, now, id)
+	} else {
+		_, err = tx.Exec(`
+				UPDATE todos
+				SET status = ?, done = 0, completed_at = NULL
+				WHERE id = ?
+			`, status, id)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Finished work stops its timer
+	if status == StatusDone || status == StatusCancelled {
+		_, err = tx.Exec(`UPDATE sessions SET ended_at = ? WHERE todo_id = ? AND ended_at IS NULL`, now, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+			INSERT INTO status_transitions
+			(todo_id, from_status, to_status, changed_at) VALUES (?, ?, ?, ?)
+		`, id, from, status, now)
+	if err != nil {
+		return err
+	}
+
+	if status == StatusDone && rule != "" {
+		recurrence, err := ParseRecurrence(rule)
+		if err != nil {
+			return err
+		}
+		// Series from before monthly rules kept their day get pinned here
+		recurrence = recurrence.Anchor(due.Time.In(db.location))
+		_, err = insertTodo(tx, item{
+			Task:       task,
+			Recurrence: recurrence.String(),
+			DueAt:      recurrence.Next(due.Time.In(db.location), now.In(db.location)),
+			Project:    project,
+			Estimate:   estimate,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) GetTodosByStatus(status string) ([]item, error) {
+	return db.scanTodos(`
+		SELECT`+todoColumns+`
+		FROM
+				todos
+		WHERE
+				status = ?
+				AND deleted_at IS NULL;
+		`, status)
+}
+
+// Transitions of a single todo, oldest first
+func (db *DB) GetTransitions(id int) ([]transition, error) {
+	return db.scanTransitions(`
+		SELECT todo_id, from_status, to_status, changed_at
+		FROM status_transitions
+		WHERE todo_id = ?
+		ORDER BY changed_at, id;
+		`, id)
+}
+
+// Every transition recorded outside the trash, archived todos included,
+// oldest first
+func (db *DB) GetAllTransitions() ([]transition, error) {
+	return db.scanTransitions(`
+		SELECT todo_id, from_status, to_status, changed_at
+		FROM status_transitions
+		WHERE todo_id NOT IN (SELECT id FROM todos WHERE deleted_at IS NOT NULL)
+		ORDER BY changed_at, id;
+		`)
+}
+
+func (db *DB) scanTransitions(query string, args ...interface{}) ([]transition, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transitions []transition
+	for rows.Next() {
+		var tr transition
+		if err := rows.Scan(&tr.TodoID, &tr.From, &tr.To, &tr.ChangedAt); err != nil {
+			return nil, err
+		}
+		tr.ChangedAt = tr.ChangedAt.In(db.location)
+		transitions = append(transitions, tr)
+	}
+	return transitions, rows.Err()
+}
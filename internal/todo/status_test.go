@@ -0,0 +1,109 @@
+package todo
+
+import (
+	"testing"
+)
+
+func TestSetStatus(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	id := addTestTask(t, db, "Write design doc")
+
+	t.Run("Unknown status", func(t *testing.T) {
+		if err := db.SetStatus(id, "someday"); err == nil {
+			t.Error("SetStatus with an unknown status succeeded unexpectedly")
+		}
+	})
+
+	for _, status := range []string{StatusInProgress, StatusWaiting, StatusDone, StatusTodo} {
+		if err := db.SetStatus(id, status); err != nil {
+			t.Fatalf("SetStatus(%d, %q) failed: %v", id, status, err)
+		}
+	}
+
+	transitions, err := db.GetTransitions(id)
+	if err != nil {
+		t.Fatalf("GetTransitions failed: %v", err)
+	}
+	expected := [][2]string{
+		{StatusTodo, StatusInProgress},
+		{StatusInProgress, StatusWaiting},
+		{StatusWaiting, StatusDone},
+		{StatusDone, StatusTodo},
+	}
+	if len(transitions) != len(expected) {
+		t.Fatalf("Expected %d transitions, got %d", len(expected), len(transitions))
+	}
+	for i, tr := range transitions {
+		if tr.From != expected[i][0] || tr.To != expected[i][1] {
+			t.Errorf("Transition %d: expected %s -> %s, got %s -> %s", i, expected[i][0], expected[i][1], tr.From, tr.To)
+		}
+		if tr.ChangedAt.IsZero() {
+			t.Errorf("Transition %d has no timestamp", i)
+		}
+	}
+
+	// Reopening clears the completion
+	pending, err := db.GetPendingTodos()
+	if err != nil {
+		t.Fatalf("GetPendingTodos failed: %v", err)
+	}
+	if len(pending) != 1 || pending[0].Done || !pending[0].CompletedAt.IsZero() {
+		t.Errorf("Expected the reopened todo to be pending without completion time, got %+v", pending)
+	}
+
+	// Cancelled todos are neither pending nor completed
+	if err := db.SetStatus(id, StatusCancelled); err != nil {
+		t.Fatalf("SetStatus cancelled failed: %v", err)
+	}
+	pending, _ = db.GetPendingTodos()
+	if len(pending) != 0 {
+		t.Errorf("Expected no pending todos after cancelling, got %d", len(pending))
+	}
+}
+
+func TestBlockedTasks(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+
+	idWaiting := addTestTask(t, db, "Waiting on vendor")
+	idBlocker := addTestTask(t, db, "Fix CI")
+	idBlocked := addTestTask(t, db, "Merge branch")
+	addTestTask(t, db, "Free task")
+
+	if _, err := todos.Move(idWaiting, StatusWaiting); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	if err := todos.Block(idBlocked, idBlocker); err != nil {
+		t.Fatalf("Block failed: %v", err)
+	}
+
+	blocked, err := todos.GetBlockedTasks()
+	if err != nil {
+		t.Fatalf("GetBlockedTasks failed: %v", err)
+	}
+	if len(blocked) != 2 {
+		t.Fatalf("Expected 2 blocked tasks, got %v", blocked)
+	}
+
+	// Cancelling the blocker releases the dependency too
+	unblocked, err := todos.Move(idBlocker, StatusCancelled)
+	if err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	if len(unblocked) != 1 || unblocked[0].ID != idBlocked {
+		t.Errorf("Expected todo %d to be unblocked, got %v", idBlocked, unblocked)
+	}
+
+	// A dependent that is waiting on its own isn't free once its blocker is done
+	blocker := addTestTask(t, db, "Order parts")
+	if err := todos.Block(idWaiting, blocker); err != nil {
+		t.Fatalf("Block failed: %v", err)
+	}
+	unblocked, err = todos.Complete(blocker)
+	if err != nil || len(unblocked) != 0 {
+		t.Errorf("Expected the waiting todo left out, got %v (err: %v)", unblocked, err)
+	}
+}
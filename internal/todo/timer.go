@@ -0,0 +1,275 @@
+package todo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	ErrTimerRunning = errors.New("a timer is already running")
+	ErrNoTimer      = errors.New("no timer running")
+)
+
+// How a session was recorded
+const (
+	sessionTimer = "timer"
+	sessionFocus = "focus"
+)
+
+// A stretch of time worked on a todo, EndedAt is zero while it runs
+type session struct {
+	ID        int
+	TodoID    int
+	StartedAt time.Time
+	EndedAt   time.Time
+	Kind      string
+}
+
+func (s session) running() bool {
+	return s.EndedAt.IsZero()
+}
+
+// Time spent in the session within [since, until), running sessions count
+// up to now. Zero times leave that side open
+func (s session) within(since, until, now time.Time) time.Duration {
+	start, end := s.StartedAt, s.EndedAt
+	if s.running() {
+		end = now
+	}
+	if !since.IsZero() && start.Before(since) {
+		start = since
+	}
+	if !until.IsZero() && end.After(until) {
+		end = until
+	}
+	if end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// Starts a timer on a todo. Only one timer runs at a time, the unique index
+// on running sessions catches anything that slips past the check
+func (db *DB) StartTimer(id int) error {
+	return db.startSession(id, sessionTimer)
+}
+
+func (db *DB) startSession(id int, kind string) error {
+	return db.transaction(func(tx *sql.Tx) error {
+		return insertSession(tx, id, kind)
+	})
+}
+
+// Starts a session on a todo and moves it to in-progress if it is still todo,
+// in one tracked transaction so undo takes back both
+func (db *DB) startWork(id int, kind string) error {
+	return db.tracked([]int{id}, func(tx *sql.Tx) error {
+		if err := insertSession(tx, id, kind); err != nil {
+			return err
+		}
+		var status string
+		if err := tx.QueryRow(`SELECT status FROM todos WHERE id = ?`, id).Scan(&status); err != nil {
+			return err
+		}
+		if status == StatusTodo {
+			return db.setStatus(tx, id, StatusInProgress)
+		}
+		return nil
+	})
+}
+
+func insertSession(tx *sql.Tx, id int, kind string) error {
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM todos WHERE id = ? AND deleted_at IS NULL)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+
+	var running int
+	err := tx.QueryRow(`SELECT todo_id FROM sessions WHERE ended_at IS NULL`).Scan(&running)
+	if err == nil {
+		return fmt.Errorf("%w on todo %d", ErrTimerRunning, running)
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO sessions (todo_id, started_at, kind) VALUES (?, ?, ?)`, id, utcNow(), kind)
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return ErrTimerRunning
+	}
+	return err
+}
+
+// Stops the running timer and returns the finished session
+func (db *DB) StopTimer() (session, error) {
+	var s session
+	err := db.transaction(func(tx *sql.Tx) error {
+		err := tx.QueryRow(`
+			SELECT id, todo_id, started_at, kind
+			FROM sessions
+			WHERE ended_at IS NULL;
+			`).Scan(&s.ID, &s.TodoID, &s.StartedAt, &s.Kind)
+		if err == sql.ErrNoRows {
+			return ErrNoTimer
+		}
+		if err != nil {
+			return err
+		}
+
+		s.EndedAt = utcNow()
+		_, err = tx.Exec(`UPDATE sessions SET ended_at = ? WHERE id = ?`, s.EndedAt, s.ID)
+		return err
+	})
+	if err != nil {
+		return session{}, err
+	}
+	s.StartedAt = s.StartedAt.In(db.location)
+	s.EndedAt = s.EndedAt.In(db.location)
+	return s, nil
+}
+
+// The session being timed right now, if there is one
+func (db *DB) RunningTimer() (session, bool, error) {
+	sessions, err := db.scanSessions(`
+		SELECT id, todo_id, started_at, ended_at, kind
+		FROM sessions
+		WHERE ended_at IS NULL;
+		`)
+	if err != nil || len(sessions) == 0 {
+		return session{}, false, err
+	}
+	return sessions[0], true, nil
+}
+
+// Sessions overlapping [since, until), a zero until leaves the end open.
+// Sessions of trashed todos are left out, archived ones count
+func (db *DB) GetSessions(since, until time.Time) ([]session, error) {
+	if until.IsZero() {
+		return db.scanSessions(`
+			SELECT id, todo_id, started_at, ended_at, kind
+			FROM sessions
+			WHERE (ended_at IS NULL OR ended_at > ?)
+				AND todo_id NOT IN (SELECT id FROM todos WHERE deleted_at IS NOT NULL)
+			ORDER BY started_at;
+			`, since.UTC())
+	}
+	return db.scanSessions(`
+		SELECT id, todo_id, started_at, ended_at, kind
+		FROM sessions
+		WHERE (ended_at IS NULL OR ended_at > ?) AND started_at < ?
+			AND todo_id NOT IN (SELECT id FROM todos WHERE deleted_at IS NOT NULL)
+		ORDER BY started_at;
+		`, since.UTC(), until.UTC())
+}
+
+func (db *DB) scanSessions(query string, args ...interface{}) ([]session, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []session
+	for rows.Next() {
+		var s session
+		var ended sql.NullTime
+		if err := rows.Scan(&s.ID, &s.TodoID, &s.StartedAt, &ended, &s.Kind); err != nil {
+			return nil, err
+		}
+		s.StartedAt = s.StartedAt.In(db.location)
+		if ended.Valid {
+			s.EndedAt = ended.Time.In(db.location)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// Time spent per todo within [since, until), zero times leave that side open
+func (db *DB) TimeSpent(since, until time.Time) (map[int]time.Duration, error) {
+	sessions, err := db.GetSessions(since, until)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	spent := map[int]time.Duration{}
+	for _, s := range sessions {
+		spent[s.TodoID] += s.within(since, until, now)
+	}
+	return spent, nil
+}
+
+// Focus sessions logged on a todo, finished or not
+func (db *DB) FocusCount(id int) (int, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sessions WHERE todo_id = ? AND kind = ?`, id, sessionFocus).Scan(&n)
+	return n, err
+}
+
+// Time spent for listings, empty when nothing was tracked
+func formatSpent(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return formatDuration(d)
+}
+
+// Starts working on a todo. One that was not started yet moves to in-progress
+func (t *Todos) Start(id int) error {
+	return t.start(id, sessionTimer)
+}
+
+func (t *Todos) start(id int, kind string) error {
+	item, err := t.db.GetTodo(id)
+	if err != nil {
+		return err
+	}
+	if !item.pending() {
+		return fmt.Errorf("todo %d is already %s", id, item.Status)
+	}
+	return t.journal("start", []int{id}, func(db *DB) error {
+		return db.startWork(id, kind)
+	})
+}
+
+// Stops the running timer and returns the todo it was on and how long it ran
+func (t *Todos) Stop() (item, time.Duration, error) {
+	s, err := t.db.StopTimer()
+	if err != nil {
+		return item{}, 0, err
+	}
+	i, err := t.db.GetTodo(s.TodoID)
+	return i, s.EndedAt.Sub(s.StartedAt), err
+}
+
+// Prints what the running timer is on
+func (t *Todos) PrintTimer(now time.Time) error {
+	s, ok, err := t.db.RunningTimer()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("No timer running.")
+		return nil
+	}
+
+	item, err := t.db.GetTodo(s.TodoID)
+	if err != nil {
+		return err
+	}
+	spent, err := t.db.TimeSpent(time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Working on %s %s for %s %s\n", item.Task, gray(fmt.Sprintf("#%d", item.ID)),
+		formatDuration(now.Sub(s.StartedAt)), gray(fmt.Sprintf("(since %s, %s in total)", s.StartedAt.Format("15:04"), formatDuration(spent[item.ID]))))
+	return nil
+}
@@ -0,0 +1,135 @@
+package todo
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestTimer(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+
+	first := addTestTask(t, db, "First")
+	second := addTestTask(t, db, "Second")
+
+	if _, err := db.StopTimer(); !errors.Is(err, ErrNoTimer) {
+		t.Errorf("Expected ErrNoTimer, got %v", err)
+	}
+	if err := todos.Start(first); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if item, _ := db.GetTodo(first); item.Status != StatusInProgress {
+		t.Errorf("Expected the started todo in progress, got %s", item.Status)
+	}
+
+	t.Run("Only one timer runs", func(t *testing.T) {
+		if err := db.StartTimer(second); !errors.Is(err, ErrTimerRunning) {
+			t.Errorf("Expected ErrTimerRunning, got %v", err)
+		}
+		// The index holds even when the check is skipped
+		_, err := db.Exec(`INSERT INTO sessions (todo_id, started_at) VALUES (?, ?)`, second, utcNow())
+		if err == nil {
+			t.Error("Expected the unique index to reject a second running session")
+		}
+	})
+
+	running, ok, err := db.RunningTimer()
+	if err != nil || !ok || running.TodoID != first {
+		t.Fatalf("Expected the timer on %d, got %+v, %v (err: %v)", first, running, ok, err)
+	}
+
+	item, _, err := todos.Stop()
+	if err != nil || item.ID != first {
+		t.Fatalf("Expected to stop the timer on %d, got %d (err: %v)", first, item.ID, err)
+	}
+	if _, ok, _ := db.RunningTimer(); ok {
+		t.Error("Expected no timer after stopping")
+	}
+
+	t.Run("Completing stops the timer", func(t *testing.T) {
+		if err := db.StartTimer(second); err != nil {
+			t.Fatalf("StartTimer failed: %v", err)
+		}
+		if _, err := todos.Complete(second); err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+		if _, ok, _ := db.RunningTimer(); ok {
+			t.Error("Expected the timer to stop with the todo")
+		}
+		if err := todos.Start(second); err == nil {
+			t.Error("Expected an error starting a done todo")
+		}
+	})
+
+	if err := db.DeleteTodo(second); err != nil {
+		t.Fatalf("DeleteTodo failed: %v", err)
+	}
+	if _, err := db.PurgeTodos(time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("PurgeTodos failed: %v", err)
+	}
+	var left int
+	db.QueryRow(`SELECT COUNT(*) FROM sessions WHERE todo_id = ?`, second).Scan(&left)
+	if left != 0 {
+		t.Errorf("Expected the sessions deleted with the todo, %d left", left)
+	}
+}
+
+func TestTimeSpent(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	id := addTestTask(t, db, "Tracked")
+	at := func(d, h int) time.Time {
+		return time.Date(2024, 9, d, h, 0, 0, 0, time.UTC)
+	}
+	for _, s := range [][2]time.Time{
+		{at(16, 9), at(16, 11)},
+		{at(16, 23), at(17, 1)},
+		{at(18, 9), at(18, 10)},
+	} {
+		_, err := db.Exec(`INSERT INTO sessions (todo_id, started_at, ended_at) VALUES (?, ?, ?)`, id, s[0], s[1])
+		if err != nil {
+			t.Fatalf("Inserting session failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		since, until time.Time
+		want         time.Duration
+	}{
+		{time.Time{}, time.Time{}, 5 * time.Hour},
+		// Sessions over midnight are split between the days
+		{at(16, 0), at(17, 0), 3 * time.Hour},
+		{at(17, 0), at(18, 0), time.Hour},
+		{at(18, 0), time.Time{}, time.Hour},
+	}
+	for _, tt := range tests {
+		spent, err := db.TimeSpent(tt.since, tt.until)
+		if err != nil {
+			t.Fatalf("TimeSpent failed: %v", err)
+		}
+		if spent[id] != tt.want {
+			t.Errorf("TimeSpent(%v, %v) = %v, want %v", tt.since, tt.until, spent[id], tt.want)
+		}
+	}
+}
+
+func TestRunningTimerIndex(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	id := addTestTask(t, db, "Timed")
+	if err := db.StartTimer(id); err != nil {
+		t.Fatalf("StartTimer failed: %v", err)
+	}
+
+	// What insertSession gets back when a second timer slips past its check
+	_, err := db.Exec(`INSERT INTO sessions (todo_id, started_at, kind) VALUES (?, ?, ?)`, id, utcNow(), sessionTimer)
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.ExtendedCode != sqlite3.ErrConstraintUnique {
+		t.Errorf("Expected a unique constraint violation, got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package todo
+
+import (
+	"testing"
+	"time"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("timezone %s not available: %v", name, err)
+	}
+	return loc
+}
+
+func TestRecurrenceAcrossDST(t *testing.T) {
+	ny := loadLocation(t, "America/New_York")
+	daily, _ := ParseRecurrence("daily")
+
+	// Clocks spring forward on 2024-03-10, that day is 23 hours long
+	due := time.Date(2024, 3, 10, 0, 0, 0, 0, ny)
+	next := daily.Next(due, time.Date(2024, 3, 10, 12, 0, 0, 0, ny))
+	want := time.Date(2024, 3, 11, 0, 0, 0, 0, ny)
+	if !next.Equal(want) {
+		t.Errorf("Spring forward: expected %v, got %v", want, next)
+	}
+	if next.Sub(due) != 23*time.Hour {
+		t.Errorf("Spring forward: expected a 23 hour day, got %v", next.Sub(due))
+	}
+
+	// And fall back on 2024-11-03, a 25 hour day
+	due = time.Date(2024, 11, 3, 0, 0, 0, 0, ny)
+	next = daily.Next(due, time.Date(2024, 11, 3, 23, 30, 0, 0, ny))
+	want = time.Date(2024, 11, 4, 0, 0, 0, 0, ny)
+	if !next.Equal(want) {
+		t.Errorf("Fall back: expected %v, got %v", want, next)
+	}
+	if next.Sub(due) != 25*time.Hour {
+		t.Errorf("Fall back: expected a 25 hour day, got %v", next.Sub(due))
+	}
+}
+
+func TestPreviousWorkdayAcrossDST(t *testing.T) {
+	ny := loadLocation(t, "America/New_York")
+	cal, _ := DefaultConfig().Calendar()
+
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		// Monday after spring forward, Friday was still standard time
+		{time.Date(2024, 3, 11, 8, 0, 0, 0, ny), time.Date(2024, 3, 8, 0, 0, 0, 0, ny)},
+		// Monday after fall back, Friday was still daylight time
+		{time.Date(2024, 11, 4, 8, 0, 0, 0, ny), time.Date(2024, 11, 1, 0, 0, 0, 0, ny)},
+		// Just after local midnight, when it is still the previous day in UTC
+		{time.Date(2024, 3, 12, 0, 5, 0, 0, ny), time.Date(2024, 3, 11, 0, 0, 0, 0, ny)},
+	}
+	for _, tt := range tests {
+		got := cal.PreviousWorkday(tt.now)
+		if !got.Equal(tt.want) {
+			t.Errorf("PreviousWorkday(%v) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestCompletedWindowInTimezone(t *testing.T) {
+	ny := loadLocation(t, "America/New_York")
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	db.SetLocation(ny)
+
+	// Sunday 23:30 and Monday 00:30 in New York, both on Monday in UTC
+	sunday := addTestTask(t, db, "Sunday night")
+	monday := addTestTask(t, db, "Monday morning")
+	completeAt(t, db, sunday, time.Date(2024, 3, 10, 23, 30, 0, 0, ny))
+	completeAt(t, db, monday, time.Date(2024, 3, 11, 0, 30, 0, 0, ny))
+
+	// The standup on Monday counts the local calendar day, not the UTC one
+	todos := NewTodos(db)
+	since, err := todos.StandupSince(time.Date(2024, 3, 11, 9, 0, 0, 0, ny))
+	if err != nil {
+		t.Fatalf("StandupSince failed: %v", err)
+	}
+	sundayStart := time.Date(2024, 3, 10, 0, 0, 0, 0, ny)
+	mondayStart := time.Date(2024, 3, 11, 0, 0, 0, 0, ny)
+	if !since.Equal(time.Date(2024, 3, 8, 0, 0, 0, 0, ny)) {
+		t.Errorf("Expected the standup to start Friday midnight, got %v", since)
+	}
+
+	completed, err := db.GetCompletedTodosBetween(sundayStart, mondayStart)
+	if err != nil {
+		t.Fatalf("GetCompletedTodosBetween failed: %v", err)
+	}
+	if len(completed) != 1 || completed[0].ID != sunday {
+		t.Fatalf("Expected only the Sunday todo, got %+v", completed)
+	}
+	if completed[0].CompletedAt.Location() != ny {
+		t.Errorf("Expected times in the configured zone, got %v", completed[0].CompletedAt.Location())
+	}
+	if completed[0].CompletedAt.Day() != 10 {
+		t.Errorf("Expected the completion on the 10th local time, got %v", completed[0].CompletedAt)
+	}
+
+	// Stored as UTC regardless of the process timezone
+	var stored string
+	if err := db.QueryRow(`SELECT completed_at FROM todos WHERE id = ?`, sunday).Scan(&stored); err != nil {
+		t.Fatalf("Reading raw completed_at failed: %v", err)
+	}
+	if stored[len(stored)-6:] != "+00:00" && stored[len(stored)-1:] != "Z" {
+		t.Errorf("Expected completed_at stored in UTC, got %q", stored)
+	}
+}
+
+func TestMigrateOffsetsToUTC(t *testing.T) {
+	// A todo the first release stored with the local offset of the machine
+	dbPath := baselineDB(t, `
+		INSERT INTO todos (task, done, created_at, completed_at)
+		VALUES ('Written in Berlin', 1, '2024-03-10 09:00:00+02:00', '2024-03-10 18:30:00+02:00');`)
+
+	db, err := NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("NewDB failed: %v", err)
+	}
+	defer db.Close()
+	if err := db.InitSchema(); err != nil {
+		t.Fatalf("InitSchema failed: %v", err)
+	}
+
+	// Cast so the driver hands back the stored text instead of parsing it
+	var created, completed string
+	if err := db.QueryRow(`SELECT CAST(created_at AS TEXT), CAST(completed_at AS TEXT) FROM todos WHERE id = 1`).Scan(&created, &completed); err != nil {
+		t.Fatalf("Reading raw times failed: %v", err)
+	}
+	if created != "2024-03-10 07:00:00.000+00:00" || completed != "2024-03-10 16:30:00.000+00:00" {
+		t.Errorf("Expected the times rewritten in UTC, got %q and %q", created, completed)
+	}
+
+	item, err := db.GetTodo(1)
+	if err != nil {
+		t.Fatalf("GetTodo failed: %v", err)
+	}
+	berlin := time.FixedZone("CEST", 2*60*60)
+	if want := time.Date(2024, 3, 10, 9, 0, 0, 0, berlin); !item.CreatedAt.Equal(want) {
+		t.Errorf("Expected created_at %v, got %v", want, item.CreatedAt)
+	}
+	if want := time.Date(2024, 3, 10, 18, 30, 0, 0, berlin); !item.CompletedAt.Equal(want) {
+		t.Errorf("Expected completed_at %v, got %v", want, item.CompletedAt)
+	}
+}
@@ -4,6 +4,7 @@ package todo
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/alexeyco/simpletable"
@@ -11,30 +12,206 @@ import (
 
 type item struct {
 	ID          int
+	UID         string
 	Task        string
 	Done        bool
 	CreatedAt   time.Time
 	CompletedAt time.Time
+	Recurrence  string
+	DueAt       time.Time
+	Status      string
+	Project     string
+	Estimate    string
+	DeletedAt   time.Time
+	Blocked     bool
 }
 
 type Todos struct {
-	db *DB
+	db       *DB
+	config   Config
+	calendar Calendar
 }
 
 func NewTodos(db *DB) *Todos {
-	return &Todos{db: db}
+	config := DefaultConfig()
+	// The defaults have no holidays file to read, so this cannot fail
+	calendar, _ := config.Calendar()
+	return &Todos{db: db, config: config, calendar: calendar}
+}
+
+// Applies the user's config, see LoadConfig
+func (t *Todos) SetConfig(config Config) error {
+	calendar, err := config.Calendar()
+	if err != nil {
+		return err
+	}
+	loc, err := config.Location()
+	if err != nil {
+		return err
+	}
+	t.config = config
+	t.calendar = calendar
+	t.db.SetLocation(loc)
+	t.db.SetBackupKeep(config.BackupKeep)
+	t.db.SetQueryTimeout(time.Duration(config.QueryTimeoutSeconds) * time.Second)
+	return nil
 }
 
 func (t *Todos) Add(task string) error {
-	return t.db.AddTodo(task)
+	return t.journal("add", nil, func(db *DB) error {
+		return db.AddTodo(task)
+	})
+}
+
+// Optional attributes for a new todo
+type AddOptions struct {
+	Project string
+	// Recurrence rule, see ParseRecurrence for the syntax
+	Recurrence string
+	// Points or a duration, see ParseEstimate
+	Estimate string
+}
+
+func (t *Todos) AddWithOptions(task string, opts AddOptions) error {
+	i := item{Task: task, Project: opts.Project}
+
+	if opts.Recurrence != "" {
+		recurrence, err := ParseRecurrence(opts.Recurrence)
+		if err != nil {
+			return err
+		}
+		i.DueAt = recurrence.First(time.Now().In(t.db.location))
+		i.Recurrence = recurrence.Anchor(i.DueAt).String()
+	}
+
+	if opts.Estimate != "" {
+		estimate, err := ParseEstimate(opts.Estimate)
+		if err != nil {
+			return err
+		}
+		i.Estimate = estimate.String()
+	}
+
+	return t.journal("add", nil, func(db *DB) error {
+		_, err := db.addTodo(i)
+		return err
+	})
+}
+
+// Adds a todo repeating on the given rule, see ParseRecurrence for the syntax
+func (t *Todos) AddRecurring(task, rule string) error {
+	return t.AddWithOptions(task, AddOptions{Recurrence: rule})
+}
+
+// Completes a todo and returns the todos it was the last pending blocker of
+func (t *Todos) Complete(id int) ([]item, error) {
+	return t.Move(id, StatusDone)
+}
+
+// Moves a todo to another workflow state. Like Complete, it returns the todos
+// that got unblocked when the move closes out a blocker: the ones that were
+// blocked before and are free to work on now
+func (t *Todos) Move(id int, status string) ([]item, error) {
+	name := "move to " + status
+	if status == StatusDone {
+		name = "complete"
+	}
+
+	before, err := t.db.GetDependents(id)
+	if err != nil {
+		return nil, err
+	}
+	wasBlocked := map[int]bool{}
+	for _, item := range before {
+		wasBlocked[item.ID] = item.Blocked
+	}
+
+	err = t.journal(name, []int{id}, func(db *DB) error {
+		return db.SetStatus(id, status)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dependents, err := t.db.GetDependents(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var unblocked []item
+	for _, item := range dependents {
+		if wasBlocked[item.ID] && item.pending() && !item.stuck() {
+			unblocked = append(unblocked, item)
+		}
+	}
+	return unblocked, nil
+}
+
+// Marks blockedID as waiting on blockerID
+func (t *Todos) Block(blockedID, blockerID int) error {
+	return t.journal("block", []int{blockedID, blockerID}, func(db *DB) error {
+		return db.AddDependency(blockerID, blockedID)
+	})
 }
 
-func (t *Todos) Complete(id int) error {
-	return t.db.CompleteTodo(id)
+func (t *Todos) Unblock(blockedID, blockerID int) error {
+	return t.journal("unblock", []int{blockedID, blockerID}, func(db *DB) error {
+		return db.RemoveDependency(blockerID, blockedID)
+	})
+}
+
+// Timezone days are counted in
+func (t *Todos) Location() *time.Location {
+	return t.db.location
+}
+
+func (t *Todos) Edit(id int, task string) error {
+	if strings.TrimSpace(task) == "" {
+		return fmt.Errorf("task of todo %d cannot be empty", id)
+	}
+	return t.journal("edit", []int{id}, func(db *DB) error {
+		return db.UpdateTask(id, task)
+	})
 }
 
+func (t *Todos) SetProject(id int, project string) error {
+	return t.journal("assign", []int{id}, func(db *DB) error {
+		return db.SetProject(id, project)
+	})
+}
+
+func (t *Todos) SetEstimate(id int, estimate string) error {
+	e, err := ParseEstimate(estimate)
+	if err != nil {
+		return err
+	}
+	return t.journal("estimate", []int{id}, func(db *DB) error {
+		return db.SetEstimate(id, e)
+	})
+}
+
+// Moves a todo to the trash. Unlike DeleteTodo it reports IDs that aren't
+// there, so nobody gets told a missing todo was trashed
 func (t *Todos) Delete(id int) error {
-	return t.db.DeleteTodo(id)
+	exists, err := t.db.todoExists(id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	return t.journal("delete", []int{id}, func(db *DB) error {
+		return db.DeleteTodo(id)
+	})
+}
+
+func (i item) pending() bool {
+	return i.Status != StatusDone && i.Status != StatusCancelled
+}
+
+// Blocked either by hand or by a pending dependency
+func (i item) stuck() bool {
+	return i.Blocked || i.Status == StatusBlocked || i.Status == StatusWaiting
 }
 
 // I dont think this is being used right now...
@@ -42,29 +219,47 @@ func (t *Todos) List() ([]item, error) {
 	return t.db.GetAllTodos()
 }
 
-func (t *Todos) Print() error {
-	lookbackDate := time.Now().AddDate(0, 0, -1)
+// Todos completed since yesterday followed by everything still pending
+func (t *Todos) recentAndPending() ([]item, error) {
+	lookbackDate := startOfDay(time.Now().In(t.db.location)).AddDate(0, 0, -1)
 
 	completedTodos, err := t.db.GetCompletedTodos(lookbackDate)
 	if err != nil {
-		fmt.Errorf("Error loading completed todos: %v", err)
-		return err
+		return nil, fmt.Errorf("Error loading completed todos: %w", err)
 	}
 
 	pendingTodos, err := t.db.GetPendingTodos()
 	if err != nil {
-		fmt.Errorf("Error loading pending todos: %v", err)
+		return nil, fmt.Errorf("Error loading pending todos: %w", err)
+	}
+
+	return append(completedTodos, pendingTodos...), nil
+}
+
+func (t *Todos) Print() error {
+	todos, err := t.recentAndPending()
+	if err != nil {
 		return err
 	}
 
-	todos := append(completedTodos, pendingTodos...)
+	handles, err := t.db.Handles()
+	if err != nil {
+		return err
+	}
+
+	spent, err := t.db.TimeSpent(time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
 
 	table := simpletable.New()
 	table.Header = &simpletable.Header{
 		Cells: []*simpletable.Cell{
 			{Align: simpletable.AlignCenter, Text: "ID"},
 			{Align: simpletable.AlignCenter, Text: "Task"},
-			{Align: simpletable.AlignCenter, Text: "Done"},
+			{Align: simpletable.AlignCenter, Text: "Status"},
+			{Align: simpletable.AlignRight, Text: "Est"},
+			{Align: simpletable.AlignRight, Text: "Spent"},
 			{Align: simpletable.AlignRight, Text: "CreatedAt"},
 			{Align: simpletable.AlignRight, Text: "CompletedAt"},
 		},
@@ -73,16 +268,23 @@ func (t *Todos) Print() error {
 	var cells [][]*simpletable.Cell
 
 	for _, item := range todos {
-		task := blue(item.Task)
-		done := blue("No")
+		style := item.style()
+		task := style(item.Task)
+		done := style(item.Status)
 		if item.Done {
-			task = green(fmt.Sprintf("* %s", item.Task))
-			done = green("Yes")
+			task = style(fmt.Sprintf("* %s", item.Task))
+		} else if item.Blocked {
+			done = style(StatusBlocked)
+		}
+		if item.Recurrence != "" {
+			task += gray(fmt.Sprintf(" (%s, due %s)", item.Recurrence, item.DueAt.Format("Mon Jan 2")))
 		}
 		cells = append(cells, []*simpletable.Cell{
-			{Text: fmt.Sprintf("%d", item.ID)},
+			{Text: fmt.Sprintf("%d %s", item.ID, gray(handles[item.ID]))},
 			{Text: task},
 			{Text: done},
+			{Align: simpletable.AlignRight, Text: item.Estimate},
+			{Align: simpletable.AlignRight, Text: formatSpent(spent[item.ID])},
 			{Text: item.CreatedAt.Format(time.RFC822)},
 			{Text: item.CompletedAt.Format(time.RFC822)},
 		})
@@ -91,7 +293,7 @@ func (t *Todos) Print() error {
 	table.Body = &simpletable.Body{Cells: cells}
 
 	table.Footer = &simpletable.Footer{Cells: []*simpletable.Cell{
-		{Align: simpletable.AlignCenter, Span: 5, Text: red(fmt.Sprintf("you have %d pending todos", t.CountPending()))},
+		{Align: simpletable.AlignCenter, Span: 7, Text: red(t.pendingSummary())},
 	}}
 
 	table.SetStyle(simpletable.StyleUnicode)
@@ -100,6 +302,75 @@ func (t *Todos) Print() error {
 	return nil
 }
 
+// Prints every attribute of a single todo
+func (t *Todos) Show(id int) error {
+	item, err := t.db.GetTodo(id)
+	if err != nil {
+		return err
+	}
+
+	handles, err := t.db.Handles()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s %s\n", item.style()(item.Task), gray(fmt.Sprintf("#%d", item.ID)))
+	fmt.Printf("  Handle:    %s\n", handles[item.ID])
+	fmt.Printf("  UID:       %s\n", item.UID)
+	fmt.Printf("  Status:    %s\n", item.Status)
+	if item.Project != "" {
+		fmt.Printf("  Project:   %s\n", item.Project)
+	}
+	if item.Estimate != "" {
+		fmt.Printf("  Estimate:  %s\n", item.Estimate)
+	}
+	if item.Recurrence != "" {
+		fmt.Printf("  Repeats:   %s, due %s\n", item.Recurrence, item.DueAt.Format("Mon Jan 2 2006"))
+	}
+	fmt.Printf("  Created:   %s\n", item.CreatedAt.Format(time.RFC822))
+	if !item.CompletedAt.IsZero() {
+		fmt.Printf("  Completed: %s\n", item.CompletedAt.Format(time.RFC822))
+	}
+
+	spent, err := t.db.TimeSpent(time.Time{}, time.Time{})
+	if err != nil {
+		return err
+	}
+	if spent[id] > 0 {
+		fmt.Printf("  Spent:     %s\n", formatDuration(spent[id]))
+	}
+	focus, err := t.db.FocusCount(id)
+	if err != nil {
+		return err
+	}
+	if focus > 0 {
+		fmt.Printf("  Focus:     %d sessions\n", focus)
+	}
+
+	blockers, err := t.db.GetBlockers(id)
+	if err != nil {
+		return err
+	}
+	for _, blocker := range blockers {
+		fmt.Printf("  Blocked by: %s %s\n", handles[blocker.ID], blocker.style()(blocker.Task))
+	}
+	return nil
+}
+
+// Footer of the listing: how many todos are pending and how much work they add up to
+func (t *Todos) pendingSummary() string {
+	pending, err := t.db.GetPendingTodos()
+	// Like CountPending, a failed query reads as nothing pending
+	summary := fmt.Sprintf("you have %d pending todos", len(pending))
+	if err != nil {
+		return summary
+	}
+	if estimated := formatEstimates(sumEstimates(pending)); estimated != "" {
+		summary += fmt.Sprintf(" (%s estimated)", estimated)
+	}
+	return summary
+}
+
 func (t *Todos) CountPending() int {
 	todos, err := t.db.GetPendingTodos()
 	// TODO: Handle this excpetion better...
@@ -109,43 +380,110 @@ func (t *Todos) CountPending() int {
 	return len(todos)
 }
 
+const lastStandupKey = "last_standup"
+
+// Start of the period a standup on currentTime reports on. By default that is
+// the previous workday in the calendar, in last-standup mode it is the last
+// time RecordStandup was called
+func (t *Todos) StandupSince(currentTime time.Time) (time.Time, error) {
+	if t.config.StandupMode == StandupLastRun {
+		last, ok, err := t.db.GetMeta(lastStandupKey)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if ok {
+			return time.Parse(time.RFC3339Nano, last)
+		}
+	}
+	return t.calendar.PreviousWorkday(currentTime.In(t.db.location)), nil
+}
+
+// Remembers when the standup was given, for the last-standup mode
+func (t *Todos) RecordStandup(at time.Time) error {
+	return t.db.SetMeta(lastStandupKey, at.Format(time.RFC3339Nano))
+}
+
 func (t *Todos) GetStandupTasks(currentTime time.Time) ([]string, time.Time) {
-	// Get the current day
-	weekday := currentTime.Weekday()
-	var lookbackDays int
-	if weekday == time.Monday {
-		// If it is a Monday, look back 3 day
-		lookbackDays = 3
-	} else {
-		// Any other day, just use 1 day
-		lookbackDays = 1
+	lookbackDate, err := t.StandupSince(currentTime)
+	if err != nil {
+		return nil, lookbackDate
 	}
-	lookbackDate := currentTime.AddDate(0, 0, -lookbackDays)
 
-	todos, err := t.db.GetCompletedTodos(lookbackDate)
+	tasks, err := t.GetCompletedTasks(lookbackDate, time.Time{})
 	if err != nil {
 		return nil, lookbackDate
 	}
 
+	return tasks, lookbackDate
+}
+
+// Task names of the todos completed in (since, until], a zero until leaves the end open
+func (t *Todos) GetCompletedTasks(since, until time.Time) ([]string, error) {
+	todos, err := t.db.GetCompletedTodosBetween(since, until)
+	if err != nil {
+		return nil, err
+	}
+
 	var tasks []string
 	for _, item := range todos {
 		tasks = append(tasks, item.Task)
 	}
 
-	return tasks, lookbackDate
+	return tasks, nil
 }
 
-func (t *Todos) GetTasks(currentTime time.Time) ([]string, time.Time) {
+// Pending tasks for today, blocked ones are left out unless includeBlocked is set
+func (t *Todos) GetTasks(currentTime time.Time, includeBlocked bool) ([]string, time.Time) {
 	todos, err := t.db.GetPendingTodos()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error getting the pending todos: %v\n", err)
 		return nil, currentTime
 	}
 
+	// Recurring todos show up once they are due
+	endOfDay := startOfDay(currentTime.In(t.db.location)).AddDate(0, 0, 1)
+
 	var tasks []string
 	for _, item := range todos {
+		if item.stuck() && !includeBlocked {
+			continue
+		}
+		if !item.DueAt.IsZero() && !item.DueAt.Before(endOfDay) {
+			continue
+		}
 		tasks = append(tasks, item.Task)
 	}
 
 	return tasks, currentTime
 }
+
+// Task names of the todos currently in the given state
+func (t *Todos) GetTasksInStatus(status string) ([]string, error) {
+	todos, err := t.db.GetTodosByStatus(status)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []string
+	for _, item := range todos {
+		tasks = append(tasks, item.Task)
+	}
+	return tasks, nil
+}
+
+// Task names of pending todos that cannot move on, either because they were
+// set to blocked/waiting or because one of their dependencies is still open
+func (t *Todos) GetBlockedTasks() ([]string, error) {
+	todos, err := t.db.GetPendingTodos()
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []string
+	for _, item := range todos {
+		if item.stuck() {
+			tasks = append(tasks, item.Task)
+		}
+	}
+	return tasks, nil
+}
@@ -0,0 +1,192 @@
+package todo
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexeyco/simpletable"
+)
+
+// Brings a trashed todo back
+func (db *DB) RestoreTodo(id int) error {
+	return db.tracked([]int{id}, func(tx *sql.Tx) error {
+		res, err := tx.Exec(`UPDATE todos SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`, id)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return fmt.Errorf("%w in the trash: %d", ErrNotFound, id)
+		}
+		return nil
+	})
+}
+
+// Trashed todos, most recently deleted first
+func (db *DB) GetTrashedTodos() ([]item, error) {
+	return db.scanTodos(`
+		SELECT` + todoColumns + `
+		FROM
+				todos
+		WHERE
+				deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC;
+		`)
+}
+
+// Deletes todos trashed before the given time for good, along with
+// everything recorded about them but their history. Returns how many were purged
+func (db *DB) PurgeTodos(before time.Time) (int, error) {
+	ids, err := db.trashedBefore(before)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	if _, err := db.Backup("purge"); err != nil {
+		return 0, err
+	}
+
+	// Only what was looked at above, anything trashed since waits for next time
+	var n int64
+	err = db.tracked(ids, func(tx *sql.Tx) error {
+		// Restored in the meantime, it stays
+		trashed, err := idsOf(tx, `SELECT id FROM todos WHERE deleted_at IS NOT NULL AND id IN (`+placeholders(len(ids))+`)`, intArgs(ids)...)
+		if err != nil || len(trashed) == 0 {
+			return err
+		}
+
+		in, args := placeholders(len(trashed)), intArgs(trashed)
+		_, err = tx.Exec(`
+			DELETE FROM dependencies WHERE blocker_id IN (`+in+`) OR blocked_id IN (`+in+`);
+			DELETE FROM status_transitions WHERE todo_id IN (`+in+`);
+			DELETE FROM sessions WHERE todo_id IN (`+in+`);
+			`, append(append(append(args, args...), args...), args...)...)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.Exec(`DELETE FROM todos WHERE id IN (`+in+`)`, args...)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	return int(n), err
+}
+
+// IDs of the todos trashed before the given time
+func (db *DB) trashedBefore(before time.Time) ([]int, error) {
+	return db.ids(`SELECT id FROM todos WHERE deleted_at IS NOT NULL AND deleted_at < ? ORDER BY id`, before.UTC())
+}
+
+// Turns an ID or a handle from the trash listing into an ID
+func (t *Todos) ResolveTrashed(ref string) (int, error) {
+	ref = strings.TrimSpace(ref)
+	id, numErr := strconv.Atoi(ref)
+	if numErr == nil {
+		var trashed bool
+		err := t.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM todos WHERE id = ? AND deleted_at IS NOT NULL)`, id).Scan(&trashed)
+		if err != nil {
+			return 0, err
+		}
+		if trashed {
+			return id, nil
+		}
+	}
+
+	// Same as Resolve, digits alone are always an ID
+	if prefix := compactUID(ref); len(prefix) >= minHandleLength && isHex(prefix) && strings.ContainsAny(prefix, "abcdef") {
+		ids, err := t.db.findByUIDPrefix(prefix, true)
+		if err != nil {
+			return 0, err
+		}
+		if len(ids) > 1 {
+			return 0, fmt.Errorf("handle %q matches %d trashed todos, use a longer one", ref, len(ids))
+		}
+		if len(ids) == 1 {
+			return ids[0], nil
+		}
+	}
+
+	// Unknown IDs are passed on, restoring them reports they are not in the trash
+	if numErr == nil {
+		return id, nil
+	}
+	return 0, fmt.Errorf("%w in the trash: %q", ErrNotFound, ref)
+}
+
+func (t *Todos) Restore(id int) error {
+	return t.journal("restore", []int{id}, func(db *DB) error {
+		return db.RestoreTodo(id)
+	})
+}
+
+// Empties the trash of everything deleted more than olderThan ago
+func (t *Todos) Purge(olderThan time.Duration) (int, error) {
+	before := time.Now().Add(-olderThan)
+
+	var n int
+	err := t.journal("purge", nil, func(db *DB) error {
+		var err error
+		n, err = db.PurgeTodos(before)
+		return err
+	})
+	return n, err
+}
+
+func (t *Todos) PrintTrash() error {
+	trashed, err := t.db.GetTrashedTodos()
+	if err != nil {
+		return err
+	}
+	if len(trashed) == 0 {
+		fmt.Println("The trash is empty.")
+		return nil
+	}
+	handles, err := t.db.TrashHandles()
+	if err != nil {
+		return err
+	}
+
+	table := simpletable.New()
+	table.Header = &simpletable.Header{
+		Cells: []*simpletable.Cell{
+			{Align: simpletable.AlignCenter, Text: "ID"},
+			{Align: simpletable.AlignCenter, Text: "Handle"},
+			{Align: simpletable.AlignCenter, Text: "Task"},
+			{Align: simpletable.AlignCenter, Text: "Status"},
+			{Align: simpletable.AlignRight, Text: "DeletedAt"},
+		},
+	}
+	for _, item := range trashed {
+		table.Body.Cells = append(table.Body.Cells, []*simpletable.Cell{
+			{Text: strconv.Itoa(item.ID)},
+			{Text: gray(handles[item.ID])},
+			{Text: gray(item.Task)},
+			{Text: gray(item.Status)},
+			{Text: item.DeletedAt.Format(time.RFC822)},
+		})
+	}
+	table.SetStyle(simpletable.StyleUnicode)
+	table.Println()
+	return nil
+}
+
+// Parses an age like 30d, 2w or anything time.ParseDuration takes
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			if days, err := strconv.Atoi(n); err == nil && days >= 0 {
+				return time.Duration(days) * unit, nil
+			}
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q, expected something like 30d, 2w or 12h", s)
+	}
+	return d, nil
+}
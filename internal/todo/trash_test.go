@@ -0,0 +1,197 @@
+package todo
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+
+	blocker := addTestTask(t, db, "Blocker")
+	blocked := addTestTask(t, db, "Blocked")
+	if err := todos.Block(blocked, blocker); err != nil {
+		t.Fatalf("Block failed: %v", err)
+	}
+	if err := todos.Start(blocker); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	if err := todos.Delete(blocker); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := todos.Delete(blocker); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a trashed todo again, got %v", err)
+	}
+	if err := todos.Delete(999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a missing todo, got %v", err)
+	}
+
+	// Gone from every query
+	all, err := db.GetAllTodos()
+	if err != nil {
+		t.Fatalf("GetAllTodos failed: %v", err)
+	}
+	if len(all) != 1 || all[0].ID != blocked {
+		t.Fatalf("Expected only todo %d left, got %v", blocked, all)
+	}
+	if all[0].Blocked {
+		t.Error("Expected a trashed blocker not to block anymore")
+	}
+	if _, err := db.GetTodo(blocker); err == nil {
+		t.Error("Expected GetTodo to miss a trashed todo")
+	}
+	if _, ok, _ := db.RunningTimer(); ok {
+		t.Error("Expected the timer to stop when its todo is trashed")
+	}
+	if err := todos.Start(blocker); err == nil {
+		t.Error("Expected an error starting a trashed todo")
+	}
+
+	trashed, err := db.GetTrashedTodos()
+	if err != nil {
+		t.Fatalf("GetTrashedTodos failed: %v", err)
+	}
+	if len(trashed) != 1 || trashed[0].ID != blocker || trashed[0].DeletedAt.IsZero() {
+		t.Fatalf("Expected todo %d in the trash, got %v", blocker, trashed)
+	}
+
+	// Restoring brings back the todo with its dependency
+	if err := todos.Restore(blocker); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if err := todos.Restore(blocker); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound restoring a todo outside the trash, got %v", err)
+	}
+	item, err := db.GetTodo(blocked)
+	if err != nil {
+		t.Fatalf("GetTodo failed: %v", err)
+	}
+	if !item.Blocked {
+		t.Error("Expected the restored blocker to block again")
+	}
+}
+
+func TestResolveTrashed(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+
+	uids := []string{
+		"abcd1234-0000-4000-8000-000000000000",
+		"abcd1299-0000-4000-8000-000000000000",
+	}
+	ids := make([]int, len(uids))
+	for n, uid := range uids {
+		id, err := insertTodo(db, item{Task: "Task " + uid[:8], UID: uid})
+		if err != nil {
+			t.Fatalf("insertTodo failed: %v", err)
+		}
+		ids[n] = id
+	}
+	if err := todos.Delete(ids[0]); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	// Only trashed todos count, so the shorter handle is enough
+	handles, err := db.TrashHandles()
+	if err != nil {
+		t.Fatalf("TrashHandles failed: %v", err)
+	}
+	if len(handles) != 1 || handles[ids[0]] != "abcd" {
+		t.Fatalf("Expected handle abcd for the trashed todo, got %v", handles)
+	}
+
+	for _, ref := range []string{"abcd", uids[0], strconv.Itoa(ids[0])} {
+		id, err := todos.ResolveTrashed(ref)
+		if err != nil || id != ids[0] {
+			t.Errorf("ResolveTrashed(%q) = %d (err: %v), want %d", ref, id, err, ids[0])
+		}
+	}
+	if _, err := todos.ResolveTrashed("abcd1299"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a todo outside the trash, got %v", err)
+	}
+	// A UID starting with digits is not found by those digits alone
+	digits, err := insertTodo(db, item{Task: "Digits", UID: "12345678-0000-4000-8000-000000000000"})
+	if err != nil {
+		t.Fatalf("insertTodo failed: %v", err)
+	}
+	if err := todos.Delete(digits); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if id, err := todos.ResolveTrashed("1234"); err != nil || id != 1234 {
+		t.Errorf("Expected 1234 passed on as an ID, got %d (err: %v)", id, err)
+	}
+	if _, err := todos.ResolveTrashed("Task"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for text, got %v", err)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	todos := NewTodos(db)
+
+	old := addTestTask(t, db, "Old")
+	recent := addTestTask(t, db, "Recent")
+	kept := addTestTask(t, db, "Kept")
+	for _, id := range []int{old, recent} {
+		if err := todos.Delete(id); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	if _, err := db.Exec(`UPDATE todos SET deleted_at = ? WHERE id = ?`, time.Now().AddDate(0, 0, -40).UTC(), old); err != nil {
+		t.Fatalf("Failed to age the deleted todo: %v", err)
+	}
+
+	n, err := todos.Purge(30 * 24 * time.Hour)
+	if err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Expected 1 todo purged, got %d", n)
+	}
+
+	trashed, err := db.GetTrashedTodos()
+	if err != nil {
+		t.Fatalf("GetTrashedTodos failed: %v", err)
+	}
+	if len(trashed) != 1 || trashed[0].ID != recent {
+		t.Errorf("Expected only todo %d left in the trash, got %v", recent, trashed)
+	}
+	if err := todos.Restore(old); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a purged todo to be gone, got %v", err)
+	}
+	if _, err := db.GetTodo(kept); err != nil {
+		t.Errorf("Expected todo %d untouched, got %v", kept, err)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{"30d", 30 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"12h", 12 * time.Hour, false},
+		{"0d", 0, false},
+		{"-1d", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAge(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseAge(%q) error = %v, want error %v", tt.in, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAge(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
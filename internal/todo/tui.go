@@ -0,0 +1,358 @@
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/term"
+)
+
+// Lists the TUI can switch between
+const (
+	viewPending = iota
+	viewCompleted
+	viewStandup
+)
+
+var viewNames = []string{"Pending", "Completed", "Standup"}
+
+// What the keyboard is currently driving
+const (
+	modeNormal = iota
+	modeFilter
+	modeAdd
+	modeEdit
+	modeConfirmDelete
+)
+
+const tuiHelp = "j/k move  space toggle  e edit  a add  d delete  / filter  tab view  q quit"
+
+// State of the full-screen mode. Every change goes through the Todos methods
+// so the TUI behaves exactly like the flags do
+type tui struct {
+	todos   *Todos
+	items   []item
+	view    int
+	mode    int
+	cursor  int
+	offset  int
+	filter  string
+	input   string
+	message string
+	height  int
+	width   int
+}
+
+// Runs the interactive mode on the terminal until the user quits
+func (t *Todos) RunTUI() error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return errors.New("tui needs an interactive terminal")
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	// Alternate screen with a hidden cursor, both undone on the way out
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	u := &tui{todos: t}
+	if err := u.reload(); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 16)
+	for {
+		u.width, u.height, err = term.GetSize(fd)
+		if err != nil {
+			u.width, u.height = defaultTermWidth, 24
+		}
+		u.render(os.Stdout)
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return err
+		}
+		quit, err := u.handle(parseKey(buf[:n]))
+		if err != nil {
+			u.message = err.Error()
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// Turns raw terminal input into key names, printable characters are returned as is
+func parseKey(b []byte) string {
+	switch string(b) {
+	case "\x1b[A", "\x1bOA":
+		return "up"
+	case "\x1b[B", "\x1bOB":
+		return "down"
+	case "\r", "\n":
+		return "enter"
+	case "\x7f", "\b":
+		return "backspace"
+	case "\t":
+		return "tab"
+	case "\x1b":
+		return "esc"
+	case "\x03":
+		return "ctrl-c"
+	}
+	return string(b)
+}
+
+// Loads the todos of the current view and applies the filter
+func (u *tui) reload() error {
+	var todos []item
+	var err error
+
+	switch u.view {
+	case viewPending:
+		todos, err = u.todos.db.GetPendingTodos()
+	case viewCompleted:
+		todos, err = u.todos.db.GetCompletedTodos(time.Time{})
+	case viewStandup:
+		var since time.Time
+		if since, err = u.todos.StandupSince(time.Now()); err == nil {
+			todos, err = u.todos.db.GetCompletedTodos(since)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	u.items = u.items[:0]
+	for _, item := range todos {
+		if u.filter == "" || strings.Contains(strings.ToLower(item.Task), strings.ToLower(u.filter)) {
+			u.items = append(u.items, item)
+		}
+	}
+
+	if u.cursor >= len(u.items) {
+		u.cursor = len(u.items) - 1
+	}
+	if u.cursor < 0 {
+		u.cursor = 0
+	}
+	return nil
+}
+
+func (u *tui) selected() (item, bool) {
+	if len(u.items) == 0 {
+		return item{}, false
+	}
+	return u.items[u.cursor], true
+}
+
+// Applies a key press, returns true once the user asked to quit
+func (u *tui) handle(key string) (bool, error) {
+	if key == "ctrl-c" {
+		return true, nil
+	}
+
+	u.message = ""
+	switch u.mode {
+	case modeFilter:
+		switch key {
+		case "enter":
+			u.mode = modeNormal
+		case "esc":
+			u.mode = modeNormal
+			u.filter = ""
+		default:
+			u.filter = editLine(u.filter, key)
+		}
+		return false, u.reload()
+
+	case modeAdd, modeEdit:
+		switch key {
+		case "esc":
+			u.mode = modeNormal
+		case "enter":
+			mode := u.mode
+			u.mode = modeNormal
+			if strings.TrimSpace(u.input) == "" {
+				return false, nil
+			}
+			if mode == modeAdd {
+				if err := u.todos.Add(u.input); err != nil {
+					return false, err
+				}
+			} else if i, ok := u.selected(); ok {
+				if err := u.todos.Edit(i.ID, u.input); err != nil {
+					return false, err
+				}
+			}
+			return false, u.reload()
+		default:
+			u.input = editLine(u.input, key)
+		}
+		return false, nil
+
+	case modeConfirmDelete:
+		u.mode = modeNormal
+		i, ok := u.selected()
+		if !ok || (key != "y" && key != "Y") {
+			return false, nil
+		}
+		if err := u.todos.Delete(i.ID); err != nil {
+			return false, err
+		}
+		u.message = fmt.Sprintf("Deleted %q", i.Task)
+		return false, u.reload()
+	}
+
+	switch key {
+	case "q":
+		return true, nil
+	case "j", "down":
+		if u.cursor < len(u.items)-1 {
+			u.cursor++
+		}
+	case "k", "up":
+		if u.cursor > 0 {
+			u.cursor--
+		}
+	case "tab":
+		u.view = (u.view + 1) % len(viewNames)
+		u.cursor = 0
+		return false, u.reload()
+	case "1", "2", "3":
+		u.view = int(key[0] - '1')
+		u.cursor = 0
+		return false, u.reload()
+	case "/":
+		u.mode = modeFilter
+	case "a":
+		u.mode = modeAdd
+		u.input = ""
+	case "e":
+		if i, ok := u.selected(); ok {
+			u.mode = modeEdit
+			u.input = i.Task
+		}
+	case "d":
+		if _, ok := u.selected(); ok {
+			u.mode = modeConfirmDelete
+		}
+	case " ", "x":
+		i, ok := u.selected()
+		if !ok {
+			return false, nil
+		}
+		status := StatusDone
+		if i.Done {
+			status = StatusTodo
+		}
+		unblocked, err := u.todos.Move(i.ID, status)
+		if err != nil {
+			return false, err
+		}
+		var names []string
+		for _, item := range unblocked {
+			names = append(names, item.Task)
+		}
+		if len(names) > 0 {
+			u.message = "Unblocked: " + strings.Join(names, ", ")
+		}
+		return false, u.reload()
+	}
+	return false, nil
+}
+
+// Applies a key to a single line input: backspace deletes, printable text is appended
+func editLine(s, key string) string {
+	switch key {
+	case "backspace":
+		if s == "" {
+			return s
+		}
+		_, size := utf8.DecodeLastRuneInString(s)
+		return s[:len(s)-size]
+	case "up", "down", "tab", "esc", "enter":
+		return s
+	}
+	if strings.ContainsAny(key, "\x1b\r\n") {
+		return s
+	}
+	return s + key
+}
+
+func (u *tui) render(w io.Writer) {
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+
+	for i, name := range viewNames {
+		label := fmt.Sprintf(" %d %s ", i+1, name)
+		if i == u.view {
+			label = "\x1b[7m" + label + "\x1b[27m"
+		}
+		b.WriteString(label)
+	}
+	if u.filter != "" {
+		b.WriteString(gray("  filter: " + u.filter))
+	}
+	b.WriteString("\r\n\r\n")
+
+	// Keep the cursor on screen, leaving room for the header and the footer
+	rows := u.height - 5
+	if rows < 1 {
+		rows = 1
+	}
+	if u.cursor < u.offset {
+		u.offset = u.cursor
+	}
+	if u.cursor >= u.offset+rows {
+		u.offset = u.cursor - rows + 1
+	}
+
+	if len(u.items) == 0 {
+		b.WriteString(gray("  nothing here") + "\r\n")
+	}
+	for i := u.offset; i < len(u.items) && i < u.offset+rows; i++ {
+		item := u.items[i]
+		marker := "[ ]"
+		if item.Done {
+			marker = "[x]"
+		}
+		line := runewidth.Truncate(fmt.Sprintf("%s %3d %s", marker, item.ID, item.Task), u.width-2, "…")
+		if i == u.cursor {
+			b.WriteString("\x1b[7m> " + line + "\x1b[27m\r\n")
+		} else {
+			b.WriteString("  " + item.style()(line) + "\r\n")
+		}
+	}
+
+	b.WriteString("\r\n")
+	switch u.mode {
+	case modeFilter:
+		b.WriteString("/" + u.filter)
+	case modeAdd:
+		b.WriteString("add: " + u.input)
+	case modeEdit:
+		b.WriteString("edit: " + u.input)
+	case modeConfirmDelete:
+		b.WriteString(red("delete this todo? [y/N]"))
+	default:
+		if u.message != "" {
+			b.WriteString(u.message)
+		} else {
+			b.WriteString(gray(tuiHelp))
+		}
+	}
+
+	io.WriteString(w, b.String())
+}
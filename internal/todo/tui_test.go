@@ -0,0 +1,112 @@
+package todo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTUIHandle(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	addTestTask(t, db, "Write release notes")
+	addTestTask(t, db, "Deploy staging")
+	addTestTask(t, db, "Deploy production")
+
+	u := &tui{todos: NewTodos(db), height: 24, width: 80}
+	if err := u.reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+
+	press := func(keys ...string) {
+		t.Helper()
+		for _, key := range keys {
+			if _, err := u.handle(key); err != nil {
+				t.Fatalf("handle(%q) failed: %v", key, err)
+			}
+		}
+	}
+	typed := func(s string) []string {
+		var keys []string
+		for _, r := range s {
+			keys = append(keys, string(r))
+		}
+		return keys
+	}
+
+	// Filter as you type
+	press("/")
+	press(typed("deploy")...)
+	press("enter")
+	if len(u.items) != 2 {
+		t.Fatalf("Expected 2 todos matching 'deploy', got %d", len(u.items))
+	}
+
+	// Toggle the second match done
+	press("j", " ")
+	pending, _ := db.GetPendingTodos()
+	if len(pending) != 2 {
+		t.Fatalf("Expected 2 pending todos after toggling, got %d", len(pending))
+	}
+
+	// Clear the filter and edit the first todo inline
+	press("/", "esc", "k", "e")
+	press(typed(" v2")...)
+	press("enter")
+	all, _ := db.GetAllTodos()
+	if all[0].Task != "Write release notes v2" {
+		t.Errorf("Expected edited task, got %q", all[0].Task)
+	}
+
+	// Add through the prompt
+	press("a")
+	press(typed("Tag release")...)
+	press("enter")
+	if len(u.items) != 3 {
+		t.Errorf("Expected 3 pending todos after adding, got %d", len(u.items))
+	}
+
+	// Deleting asks first, anything but y keeps the todo
+	press("d", "n")
+	if len(u.items) != 3 {
+		t.Errorf("Expected delete to be cancelled, got %d todos", len(u.items))
+	}
+	press("d", "y")
+	if len(u.items) != 2 {
+		t.Errorf("Expected 2 todos after confirming delete, got %d", len(u.items))
+	}
+
+	// Completed view shows the toggled todo
+	press("2")
+	if len(u.items) != 1 || u.items[0].Task != "Deploy production" {
+		t.Errorf("Expected the completed view to hold 'Deploy production', got %+v", u.items)
+	}
+
+	var out strings.Builder
+	u.render(&out)
+	if !strings.Contains(out.String(), "Deploy production") {
+		t.Errorf("Rendered screen is missing the completed todo:\n%s", out.String())
+	}
+
+	if quit, _ := u.handle("q"); !quit {
+		t.Error("Expected q to quit")
+	}
+}
+
+func TestEditLine(t *testing.T) {
+	if got := editLine("héllo", "backspace"); got != "héll" {
+		t.Errorf("backspace: got %q", got)
+	}
+	if got := editLine("hé", "backspace"); got != "h" {
+		t.Errorf("backspace over a multi-byte rune: got %q", got)
+	}
+	if got := editLine("", "backspace"); got != "" {
+		t.Errorf("backspace on empty input: got %q", got)
+	}
+	if got := editLine("ab", "up"); got != "ab" {
+		t.Errorf("navigation keys should be ignored, got %q", got)
+	}
+	if got := editLine("ab", "c"); got != "abc" {
+		t.Errorf("append: got %q", got)
+	}
+}